@@ -0,0 +1,214 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditSelection  = "selection"  // master/slave selection changed
+	AuditFence      = "fence"      // stale master fenced with read_only
+	AuditPin        = "pin"        // master pinned with PinMaster
+	AuditUnpin      = "unpin"      // master unpinned with UnpinMaster
+	AuditSwitchover = "switchover" // planned switchover finished or failed
+)
+
+// AuditRecord describes a single action performed or detected by DBs together
+// with state of all servers at that time. Servers are identified by
+// Config.Names.
+type AuditRecord struct {
+	Time            time.Time   `json:"time"`
+	Action          string      `json:"action"`
+	Target          string      `json:"target,omitempty"` // server affected by the action
+	Error           string      `json:"error,omitempty"`
+	Master          string      `json:"master,omitempty"`
+	Slave           string      `json:"slave,omitempty"`
+	LastMaster      string      `json:"last_master,omitempty"`
+	MultipleMasters bool        `json:"multiple_masters"`
+	Nodes           []AuditNode `json:"nodes"`
+}
+
+// AuditNode is a server state in AuditRecord.
+type AuditNode struct {
+	Name    string        `json:"name"`
+	Role    Role          `json:"role"`
+	Reason  string        `json:"reason,omitempty"`
+	Latency time.Duration `json:"latency_ns"`
+	Delay   time.Duration `json:"delay_ns"`
+	Errors  []string      `json:"errors,omitempty"`
+}
+
+// AuditSink durably stores audit records. It must be safe for concurrent use.
+type AuditSink interface {
+	Record(AuditRecord) error
+}
+
+// audit records action to Config.Audit sink if it is set.
+func (p *DBs) audit(action string, target *sql.DB, err error) {
+	if p.config.Audit == nil {
+		return
+	}
+
+	p.mu.RLock()
+	r := AuditRecord{
+		Time:            time.Now(),
+		Action:          action,
+		MultipleMasters: p.active.multipleMasters,
+		Nodes:           make([]AuditNode, 0, len(p.dbs)),
+	}
+	if p.active.master != nil {
+		r.Master = p.name(p.active.master)
+	}
+	if p.active.slave != nil {
+		r.Slave = p.name(p.active.slave)
+	}
+	if p.active.lastMaster != nil {
+		r.LastMaster = p.name(p.active.lastMaster)
+	}
+	for _, db := range p.dbs {
+		s := p.state[db]
+		n := AuditNode{
+			Name:    p.name(db),
+			Role:    s.role,
+			Reason:  s.reason,
+			Latency: s.latency,
+			Delay:   s.delay,
+		}
+		for _, e := range s.errs.list() {
+			n.Errors = append(n.Errors, e.Error())
+		}
+		r.Nodes = append(r.Nodes, n)
+	}
+	p.mu.RUnlock()
+
+	if target != nil {
+		r.Target = p.name(target)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if err := p.config.Audit.Record(r); err != nil {
+		p.config.Logger.Print("dbfailover: writing audit record: ", err)
+	}
+}
+
+// JSONLWriter is an AuditSink appending records as JSON lines to a file. Every
+// record is synced to disk. File is rotated once it grows over MaxSize bytes,
+// rotated files are named path.1 (newest) to path.N (oldest).
+type JSONLWriter struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewJSONLWriter opens path for appending audit records. File is rotated when
+// it exceeds maxSize bytes (never if zero), at most maxFiles rotated files are
+// kept.
+func NewJSONLWriter(path string, maxSize int64, maxFiles int) (*JSONLWriter, error) {
+	w := &JSONLWriter{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *JSONLWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.f = f
+	w.size = st.Size()
+	return nil
+}
+
+// rotate moves current file aside and opens a new one. Current file is closed
+// only once the new one is opened, on failure records are still appended to it.
+func (w *JSONLWriter) rotate() error {
+	// Current file is already moved aside if the previous rotation failed
+	// to open a new one.
+	_, err := os.Stat(w.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		if err := w.moveAside(); err != nil {
+			return err
+		}
+	}
+	old := w.f
+	if err := w.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+func (w *JSONLWriter) moveAside() error {
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if w.maxFiles > 0 {
+		return os.Rename(w.path, w.path+".1")
+	}
+	return os.Remove(w.path)
+}
+
+// Record implements AuditSink. Record is written even if rotation fails, the
+// rotation error is returned after writing it.
+func (w *JSONLWriter) Record(r AuditRecord) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var rotateErr error
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(buf)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			// Records are appended to the current file, rotation
+			// is retried once another maxSize bytes are written.
+			rotateErr = fmt.Errorf("rotating audit log: %w", err)
+			w.size = 0
+		}
+	}
+	n, err := w.f.Write(buf)
+	w.size += int64(n)
+	if err == nil {
+		err = w.f.Sync()
+	}
+	return errors.Join(rotateErr, err)
+}
+
+// Close closes the audit log file.
+func (w *JSONLWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
@@ -0,0 +1,173 @@
+package dbfailover
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *memorySink) Record(r AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *memorySink) list() []AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditRecord(nil), s.records...)
+}
+
+func TestAudit(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	sink := &memorySink{}
+	state := map[*sql.DB]dbStatus{
+		db1: {role: RoleMaster, reason: "writable without replication"},
+		db2: {role: RoleOffline, errs: checkErrors{CheckReadOnly: newCheckError(CheckReadOnly, errors.New("boom"))}},
+	}
+	p := &DBs{
+		dbs:    []*sql.DB{db1, db2},
+		state:  state,
+		active: makeSelection(state, db1),
+		config: Config{
+			Logger: nopLogger{},
+			Audit:  sink,
+			Names:  map[*sql.DB]string{db1: "a", db2: "b"},
+		},
+	}
+
+	if err := p.PinMaster(db2, 0); err != nil {
+		t.Fatalf("pinning master: %v", err)
+	}
+	p.UnpinMaster()
+
+	if len(sink.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(sink.records))
+	}
+	r := sink.records[0]
+	if r.Action != AuditPin || r.Target != "b" || r.Master != "a" {
+		t.Errorf("unexpected pin record %+v", r)
+	}
+	if len(r.Nodes) != 2 || r.Nodes[0].Reason != "writable without replication" || len(r.Nodes[1].Errors) != 1 {
+		t.Errorf("unexpected nodes in record %+v", r.Nodes)
+	}
+	if r := sink.records[1]; r.Action != AuditUnpin || r.Target != "b" {
+		t.Errorf("unexpected unpin record %+v", r)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w, err := NewJSONLWriter(path, 300, 2)
+	if err != nil {
+		t.Fatalf("opening audit log: %v", err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		err := w.Record(AuditRecord{Action: AuditSelection, Master: "a", Nodes: []AuditNode{{Name: "a", Role: RoleMaster}}})
+		if err != nil {
+			t.Fatalf("writing record: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("opening %s: %v", name, err)
+		}
+		s := bufio.NewScanner(f)
+		lines := 0
+		for s.Scan() {
+			var r AuditRecord
+			if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+				t.Errorf("%s: invalid record: %v", name, err)
+			}
+			if r.Nodes[0].Role != RoleMaster {
+				t.Errorf("%s: role does not match, got %v", name, r.Nodes[0].Role)
+			}
+			lines++
+		}
+		_ = f.Close()
+		if lines == 0 {
+			t.Errorf("%s: no records", name)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("too many rotated files kept")
+	}
+}
+
+func TestJSONLWriterRotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w, err := NewJSONLWriter(path, 100, 1)
+	if err != nil {
+		t.Fatalf("opening audit log: %v", err)
+	}
+	defer w.Close()
+
+	records := func(name string) int {
+		t.Helper()
+		buf, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		return bytes.Count(buf, []byte("\n"))
+	}
+
+	// non-empty directory in place of the rotated file makes rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+
+	record := AuditRecord{Action: AuditSelection, Master: "a", Nodes: []AuditNode{{Name: "a", Role: RoleMaster}}}
+	if err := w.Record(record); err != nil {
+		t.Fatalf("writing record: %v", err)
+	}
+	if err := w.Record(record); err == nil {
+		t.Fatal("expected rotation error")
+	}
+	if n := records(path); n != 2 {
+		t.Fatalf("expected record written despite failed rotation, got %d records", n)
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatalf("removing directory: %v", err)
+	}
+	if err := w.Record(record); err != nil {
+		t.Fatalf("writing record after failed rotation: %v", err)
+	}
+	if n := records(path + ".1"); n != 2 {
+		t.Errorf("expected 2 rotated records, got %d", n)
+	}
+	if n := records(path); n != 1 {
+		t.Errorf("expected 1 record after rotation, got %d", n)
+	}
+
+	// file moved aside by rotation which failed to open a new file
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("moving audit log: %v", err)
+	}
+	if err := w.Record(record); err != nil {
+		t.Fatalf("writing record after moved file: %v", err)
+	}
+	if n := records(path + ".1"); n != 1 {
+		t.Errorf("expected 1 rotated record, got %d", n)
+	}
+	if n := records(path); n != 1 {
+		t.Errorf("expected 1 record in new file, got %d", n)
+	}
+}
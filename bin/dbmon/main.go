@@ -21,6 +21,17 @@ func main() {
 	flag.DurationVar(&cfg.CheckInterval, "check-interval", 1500*time.Millisecond, "Interval between status checks")
 	flag.DurationVar(&cfg.CheckTimeout, "check-timeout", 1500*time.Millisecond, "Max check duration before timeout")
 	flag.DurationVar(&cfg.CheckTimeout, "max-replication-delay", 5*time.Minute, "Max allowed slave delay behind master")
+	flag.DurationVar(&cfg.OfflineMaxInterval, "offline-max-interval", 0, "Max interval between checks of offline servers")
+	flag.DurationVar(&cfg.ChangeCheckInterval, "change-check-interval", 0, "Interval between checks after server role change")
+	flag.DurationVar(&cfg.CheckJitter, "check-jitter", 0, "Max random delay added to check interval")
+	flag.BoolVar(&cfg.CheckRounds, "check-rounds", false, "Check all servers together and update selection once per round")
+	flag.DurationVar(&cfg.SettleWindow, "settle-window", 0, "Delay selection changes until stable for given duration")
+	flag.BoolVar(&cfg.StorageReplicas, "storage-replicas", false, "Treat innodb_read_only servers without replication as slaves")
+	flag.BoolVar(&cfg.MasterQuorum, "master-quorum", false, "Select master only if majority of replicas replicate from it")
+	flag.BoolVar(&cfg.DemoteOrphanedSlaves, "demote-orphaned-slaves", false, "Do not use slaves not replicating from the selected master")
+	flag.BoolVar(&cfg.PreferDirectSlaves, "prefer-direct-slaves", false, "Prefer slaves replicating directly from master")
+	flag.BoolVar(&cfg.SkipSemiSyncCheck, "skip-semi-sync-check", false, "Skip semi-sync replication status checks")
+	flag.BoolVar(&cfg.PreferSemiSyncSlaves, "prefer-semi-sync-slaves", false, "Prefer slaves acknowledging semi-sync replication")
 	flag.Parse()
 
 	var dbs []*sql.DB
@@ -43,11 +54,47 @@ func main() {
 		log.Fatal("creating dbfailover pool: ", err)
 	}
 
+	var lastEvent uint64
 	for {
+		for _, e := range db.History() {
+			if e.Seq <= lastEvent {
+				continue
+			}
+			lastEvent = e.Seq
+			switch e.Type {
+			case dbfailover.EventRole:
+				log.Printf("%s: %v -> %v (%s), latency %v, delay %v", hosts[e.DB], e.OldRole, e.NewRole, e.Reason, e.Latency, e.Delay)
+			case dbfailover.EventSelection:
+				log.Printf("selection changed, master: %s, slave: %s, multiple masters: %v", hosts[e.Master], hosts[e.Slave], e.MultipleMasters)
+			}
+		}
+
 		master := db.Master()
 		slave := db.Slave()
 		log.Print("master: ", hosts[master])
 		log.Print("slave: ", hosts[slave])
+		if delayed, err := db.DelayedE(); err == nil {
+			log.Print("delayed: ", hosts[delayed])
+		}
+		for _, n := range db.Topology().Nodes {
+			for _, err := range n.Errors {
+				log.Print(hosts[n.DB], " ", n.Role, ": ", err)
+			}
+			if len(n.Channels) > 1 {
+				for _, c := range n.Channels {
+					log.Printf("%s channel %q: running %v, delay %v", hosts[n.DB], c.Name, c.Running, c.Delay)
+				}
+			}
+			if n.SemiSyncFallback {
+				log.Print(hosts[n.DB], " ", n.Role, ": semi-sync fell back to async")
+			}
+			if n.DuplicateOf != nil {
+				log.Print(hosts[n.DB], " ", n.Role, ": same server as ", hosts[n.DuplicateOf])
+			}
+			if n.Orphaned {
+				log.Print(hosts[n.DB], " ", n.Role, ": does not replicate from master")
+			}
+		}
 		time.Sleep(time.Second)
 	}
 }
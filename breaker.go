@@ -0,0 +1,187 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker counts connection errors reported by the application. Open breaker
+// makes server offline for the cooldown duration, after it server is used
+// again (half-open) until the next reported error opens breaker again or a
+// successful query closes it.
+type breaker struct {
+	state    breakerState
+	errors   []time.Time
+	openedAt time.Time
+}
+
+// report records a query result, it returns true if breaker was opened or
+// closed.
+func (b *breaker) report(failed bool, now time.Time, cfg Config) bool {
+	b.isOpen(now, cfg)
+
+	switch {
+	case !failed && b.state == breakerHalfOpen:
+		b.state = breakerClosed
+		b.errors = b.errors[:0]
+		return true
+	case failed && b.state == breakerHalfOpen:
+		b.state = breakerOpen
+		b.openedAt = now
+		return true
+	case failed && b.state == breakerClosed:
+		n := 0
+		for _, t := range b.errors {
+			if now.Sub(t) < cfg.BreakerWindow {
+				b.errors[n] = t
+				n++
+			}
+		}
+		b.errors = append(b.errors[:n], now)
+		if len(b.errors) >= cfg.BreakerErrors {
+			b.state = breakerOpen
+			b.openedAt = now
+			b.errors = b.errors[:0]
+			return true
+		}
+	}
+	return false
+}
+
+// isOpen reports if server should be treated as offline. Breaker is moved to
+// half-open state after cooldown.
+func (b *breaker) isOpen(now time.Time, cfg Config) bool {
+	if b.state == breakerOpen && now.Sub(b.openedAt) >= cfg.BreakerCooldown {
+		b.state = breakerHalfOpen
+	}
+	return b.state == breakerOpen
+}
+
+// connError reports if application query error is caused by an unhealthy
+// server rather than by the query itself.
+func connError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	return outage(classifyError(err))
+}
+
+// ReportError feeds query result against db into a circuit breaker. Connection
+// errors (timeouts, refused or broken connections, too many connections) are
+// counted, after Config.BreakerErrors errors within Config.BreakerWindow server
+// is treated as offline without waiting for the next status check. Other
+// errors are ignored. Reporting nil error closes a half-open breaker.
+//
+// It does nothing if Config.BreakerErrors is not set or db is not monitored.
+func (p *DBs) ReportError(db *sql.DB, err error) {
+	if err != nil && !connError(err) {
+		return
+	}
+
+	p.breakerMu.Lock()
+	b, ok := p.breakers[db]
+	changed := ok && b.report(err != nil, time.Now(), p.config)
+	p.breakerMu.Unlock()
+
+	if changed {
+		select {
+		case p.breakerChanged <- struct{}{}:
+		default:
+			// recomputation is already pending
+		}
+	}
+}
+
+// openBreakers returns servers which should be treated as offline because of
+// reported errors.
+func (p *DBs) openBreakers() map[*sql.DB]bool {
+	p.breakerMu.Lock()
+	defer p.breakerMu.Unlock()
+
+	now := time.Now()
+	var open map[*sql.DB]bool
+	for db, b := range p.breakers {
+		if b.isOpen(now, p.config) {
+			if open == nil {
+				open = make(map[*sql.DB]bool)
+			}
+			open[db] = true
+		}
+	}
+	return open
+}
+
+// TrackedDB wraps a DB pool reporting results of executed queries with
+// ReportError. Errors returned by sql.Row Scan are not tracked, report them
+// manually.
+type TrackedDB struct {
+	*sql.DB
+	dbs *DBs
+}
+
+// Track wraps db to report query errors automatically, example:
+// `dbs.Track(dbs.Master()).Exec(...)`.
+func (p *DBs) Track(db *sql.DB) TrackedDB {
+	return TrackedDB{DB: db, dbs: p}
+}
+
+// ExecContext executes a query and reports its error.
+func (t TrackedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	res, err := t.DB.ExecContext(ctx, query, args...)
+	t.dbs.ReportError(t.DB, err)
+	return res, err
+}
+
+// Exec executes a query and reports its error.
+func (t TrackedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query and reports its error.
+func (t TrackedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := t.DB.QueryContext(ctx, query, args...)
+	t.dbs.ReportError(t.DB, err)
+	return rows, err
+}
+
+// Query executes a query and reports its error.
+func (t TrackedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+// BeginTx starts a transaction and reports its error.
+func (t TrackedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := t.DB.BeginTx(ctx, opts)
+	t.dbs.ReportError(t.DB, err)
+	return tx, err
+}
+
+// Begin starts a transaction and reports its error.
+func (t TrackedDB) Begin() (*sql.Tx, error) {
+	return t.BeginTx(context.Background(), nil)
+}
+
+// PingContext verifies connection and reports its error.
+func (t TrackedDB) PingContext(ctx context.Context) error {
+	err := t.DB.PingContext(ctx)
+	t.dbs.ReportError(t.DB, err)
+	return err
+}
+
+// Ping verifies connection and reports its error.
+func (t TrackedDB) Ping() error {
+	return t.PingContext(context.Background())
+}
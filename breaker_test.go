@@ -0,0 +1,85 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	cfg := Config{
+		BreakerErrors:   3,
+		BreakerWindow:   time.Second,
+		BreakerCooldown: 5 * time.Second,
+	}
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	var b breaker
+	steps := []struct {
+		msg     string
+		failed  bool
+		at      time.Duration
+		changed bool
+		open    bool
+	}{
+		{msg: "first error", failed: true, at: 0},
+		{msg: "second error", failed: true, at: 200 * time.Millisecond},
+		{msg: "first error expired", failed: true, at: 1050 * time.Millisecond},
+		{msg: "threshold reached", failed: true, at: 1100 * time.Millisecond, changed: true, open: true},
+		{msg: "errors ignored while open", failed: true, at: 2 * time.Second, open: true},
+		{msg: "half-open after cooldown, error reopens", failed: true, at: 7 * time.Second, changed: true, open: true},
+		{msg: "success while open is ignored", failed: false, at: 8 * time.Second, open: true},
+		{msg: "half-open after cooldown, success closes", failed: false, at: 13 * time.Second, changed: true},
+		{msg: "closed again", failed: true, at: 14 * time.Second},
+	}
+	for _, step := range steps {
+		changed := b.report(step.failed, at(step.at), cfg)
+		if changed != step.changed {
+			t.Errorf("%s: changed, expected %v, got %v", step.msg, step.changed, changed)
+		}
+		if open := b.isOpen(at(step.at), cfg); open != step.open {
+			t.Errorf("%s: open, expected %v, got %v", step.msg, step.open, open)
+		}
+	}
+}
+
+func TestConnError(t *testing.T) {
+	if !connError(driver.ErrBadConn) {
+		t.Errorf("bad connection is not a connection error")
+	}
+	if !connError(context.DeadlineExceeded) {
+		t.Errorf("timeout is not a connection error")
+	}
+	if connError(errors.New("duplicate entry")) {
+		t.Errorf("query error is a connection error")
+	}
+}
+
+func TestReportError(t *testing.T) {
+	db1 := &sql.DB{}
+	p := &DBs{
+		dbs:            []*sql.DB{db1},
+		config:         Config{BreakerErrors: 1, BreakerWindow: time.Second, BreakerCooldown: time.Hour},
+		breakers:       map[*sql.DB]*breaker{db1: {}},
+		breakerChanged: make(chan struct{}, 1),
+	}
+
+	p.ReportError(db1, errors.New("duplicate entry"))
+	if len(p.openBreakers()) != 0 {
+		t.Fatalf("breaker opened on query error")
+	}
+
+	p.ReportError(db1, driver.ErrBadConn)
+	if !p.openBreakers()[db1] {
+		t.Fatalf("breaker not opened on connection error")
+	}
+	select {
+	case <-p.breakerChanged:
+	default:
+		t.Errorf("selection recomputation is not requested")
+	}
+}
@@ -0,0 +1,90 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strconv"
+)
+
+// checker runs status checks of a single server. It is not safe for
+// concurrent use.
+type checker struct {
+	db        *sql.DB // application pool, identifies the server
+	pool      *sql.DB // pool used for checks
+	querier   Querier // handle checks are run through instead of pool, see Handles
+	dedicated bool
+	conn      *sql.Conn
+	dialect   dialect
+}
+
+func newChecker(db *sql.DB, cfg Config) *checker {
+	c := &checker{
+		db:        db,
+		pool:      db,
+		dedicated: cfg.DedicatedConn,
+	}
+	if m, ok := cfg.MonitorDBs[db]; ok && m != nil {
+		c.pool = m
+	}
+	if q, ok := cfg.queriers[db]; ok {
+		c.querier = q
+		c.dedicated = false
+	}
+	return c
+}
+
+func (c *checker) check(cfg Config) dbStatus {
+	if c.querier != nil {
+		return c.withAddr(c.querier, checkDBStatus(c.querier, cfg, false, &c.dialect), cfg)
+	}
+	if !c.dedicated {
+		return c.withAddr(c.pool, checkDBStatus(c.pool, cfg, false, &c.dialect), cfg)
+	}
+
+	if c.conn == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.CheckTimeout)
+		conn, err := c.pool.Conn(ctx)
+		cancel()
+		if err != nil {
+			return dbStatus{
+				role:   RoleOffline,
+				reason: "connection failed",
+				errs:   checkErrors{CheckConnect: newCheckError(CheckConnect, err)},
+			}
+		}
+		c.conn = conn
+	}
+
+	status := c.withAddr(c.conn, checkDBStatus(c.conn, cfg, true, &c.dialect), cfg)
+	if status.errs.brokenConn() {
+		// Connection might be broken, open a new one for the next
+		// check.
+		c.close()
+	}
+	return status
+}
+
+// withAddr detects master address for quorum confirmation. Master without
+// known address is not confirmed by replicas.
+func (c *checker) withAddr(q Querier, status dbStatus, cfg Config) dbStatus {
+	if !cfg.MasterQuorum || status.role != RoleMaster {
+		return status
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CheckTimeout)
+	defer cancel()
+	host, port, err := nodeAddr(ctx, q, c.db, cfg)
+	if err != nil {
+		cfg.Logger.Print("dbfailover: detecting master address: ", err)
+		return status
+	}
+	status.addr = net.JoinHostPort(host, strconv.Itoa(port))
+	return status
+}
+
+func (c *checker) close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
@@ -0,0 +1,42 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCheckerDedicatedConn(t *testing.T) {
+	pool := getDockerPool(t)
+	network := getDockerNetwork(t, pool)
+	defer pool.RemoveNetwork(network)
+
+	master, masterResource := startMasterInstance(t, pool, network)
+	defer pool.Purge(masterResource)
+
+	// application pool is offline, checks must use monitoring pool
+	app := startOfflineInstance(t)
+
+	cfg := Config{
+		CheckTimeout:        defaultCheckTimeout,
+		MaxReplicationDelay: defaultMaxReplicationDelay,
+		MonitorDBs:          map[*sql.DB]*sql.DB{app: master},
+		DedicatedConn:       true,
+	}
+	c := newChecker(app, cfg)
+	defer c.close()
+
+	if s := c.check(cfg); s.role != RoleMaster {
+		t.Fatalf("role, expected %v, got %v", RoleMaster, s.role)
+	}
+	conn := c.conn
+	if conn == nil {
+		t.Fatalf("dedicated connection is not kept")
+	}
+
+	if s := c.check(cfg); s.role != RoleMaster {
+		t.Errorf("role, expected %v, got %v", RoleMaster, s.role)
+	}
+	if c.conn != conn {
+		t.Errorf("dedicated connection is not reused between checks")
+	}
+}
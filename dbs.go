@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,29 +15,202 @@ const (
 	defaultCheckInterval       = 1500 * time.Millisecond
 	defaultCheckTimeout        = 1500 * time.Millisecond
 	defaultMaxReplicationDelay = 5 * time.Minute
+	defaultChangeChecks        = 3
+	defaultMaxStateAge         = 10 * time.Minute
+	defaultBreakerWindow       = 10 * time.Second
 )
 
 // DBs holds a list of pools of known DB servers and provides easy access for
 // getting currently active master or slave DB pool.
 type DBs struct {
-	active selection
-	stop   func()
-	config Config
-	mu     sync.RWMutex
+	dbs      []*sql.DB
+	checkers []*checker
+	state    map[*sql.DB]dbStatus
+	active   selection
+	version  uint64
+	changed  chan struct{} // closed on selection change
+	sched    *scheduler
+	pin      pin
+	fenced   map[*sql.DB]time.Time
+	errs     map[*sql.DB]lastError
+	checked  map[*sql.DB]time.Time // last successful check
+	orphans  map[*sql.DB]bool      // slaves not replicating from master
+	dups     map[*sql.DB]*sql.DB   // pools connected to the same server as another pool
+	multiple *sql.DB               // last master before multiple masters were detected, owned by run
+	history  *history
+	stop     func()
+	done     chan struct{} // closed when run returns
+	config   Config
+	mu       sync.RWMutex
+
+	updates    chan []statusUpdate // status checks results
+	overrides  chan []statusUpdate
+	switchover sync.Mutex
+
+	breakers       map[*sql.DB]*breaker
+	breakerChanged chan struct{}
+	breakerMu      sync.Mutex
+
+	checks       atomic.Uint64
+	failedChecks atomic.Uint64
 }
 
 // Config holds configuration for DB pools.
 type Config struct {
 	SkipSlaveCheck      bool
 	SkipGaleraCheck     bool
+	SkipSemiSyncCheck   bool
 	CheckInterval       time.Duration // default 1.5 sec if empty
 	CheckTimeout        time.Duration // default 1.5 sec if empty
 	MaxReplicationDelay time.Duration // default 5 min if empty
+	Logger              Logger        // warnings are discarded if empty
+	MaxTopologyAge      time.Duration // default 10 check intervals if empty
+	HistorySize         int           // default 100 events if empty, negative disables history
+	Audit               AuditSink     // selection changes and actions are not audited if empty
+	Notifiers           []Notifier    // notified about master changes and multiple masters
+
+	// FenceStaleMasters enables setting read_only flag on writable
+	// servers detected while another master is already selected. Stale
+	// masters are fenced only if they are not replicating, otherwise
+	// multiple masters state is kept until resolved manually.
+	FenceStaleMasters bool
+
+	// StateStore persists last known master and server roles. If saved
+	// state is available on start NewWithConfig does not wait for initial
+	// checks and starts from the saved topology instead. Names identify
+	// servers in the saved state, position in the slice is used for
+	// unnamed servers.
+	// Saved state older than MaxStateAge (default 10 min if empty,
+	// negative disables the limit) is ignored and initial checks are run
+	// instead.
+	StateStore  StateStore
+	Names       map[*sql.DB]string
+	MaxStateAge time.Duration
+
+	// Per server check scheduling, not used with CheckRounds.
+	// CheckIntervals overrides CheckInterval for specific servers.
+	// Interval of offline servers is doubled after every failed check up
+	// to OfflineMaxInterval, backoff is disabled if it is empty.
+	// ChangeChecks checks after server role change are done every
+	// ChangeCheckInterval. Random duration up to CheckJitter is added to
+	// every interval.
+	CheckIntervals      map[*sql.DB]time.Duration
+	OfflineMaxInterval  time.Duration
+	ChangeCheckInterval time.Duration
+	ChangeChecks        int // default 3 if ChangeCheckInterval is set
+	CheckJitter         time.Duration
+
+	// Probes are custom health checks run on every server together with
+	// built-in checks.
+	Probes []Probe
+
+	// Circuit breaker fed by errors reported with ReportError. Server is
+	// treated as offline after BreakerErrors connection errors within
+	// BreakerWindow, for BreakerCooldown duration. Breaker is disabled if
+	// BreakerErrors is empty.
+	BreakerErrors   int
+	BreakerWindow   time.Duration // default 10 sec if empty
+	BreakerCooldown time.Duration // default CheckInterval if empty
+
+	// MonitorDBs holds separate pools used to run status checks instead
+	// of the application pools, for example opened with a monitoring
+	// user DSN. DedicatedConn enables keeping a single connection
+	// reserved for status checks, checks then are run one after another.
+	// The connection is reopened after a check fails with a broken
+	// connection or a timeout.
+	MonitorDBs    map[*sql.DB]*sql.DB
+	DedicatedConn bool
+
+	// CheckRounds enables checking all servers together every
+	// CheckInterval and recomputing selection once per round instead of
+	// after every single server check.
+	CheckRounds bool
+
+	// SettleWindow delays publishing changed master/slave selection until
+	// it is detected unchanged for the given duration. This hides
+	// intermediate states during failover, including multiple masters.
+	SettleWindow time.Duration
+
+	// ReplicationChannels lists MariaDB multi-source connections or MySQL
+	// channels required to be running, use empty name for the default
+	// connection. All connections are required if empty.
+	ReplicationChannels []string
+
+	// StorageReplicas enables detecting servers with innodb_read_only set
+	// and without replication configured as slaves, for example Aurora
+	// readers replicating on storage level. Such servers are treated as
+	// offline otherwise.
+	StorageReplicas bool
+
+	// MasterQuorum enables selecting a master only if a majority of
+	// replicas replicate from it, writable servers nobody replicates from
+	// are treated as offline. Replicas are matched by comparing their
+	// Master_Host and Master_Port with ReplicationAddrs (or report_host,
+	// hostname and port variables) of master servers. Replicas not
+	// reachable or without replication configured do not vote, without
+	// any voters no master is selected. It can not be used together with
+	// SkipSlaveCheck. Master promoted by Switchover is selected before
+	// replicas confirm it.
+	MasterQuorum bool
+
+	// DemoteOrphanedSlaves enables treating slaves that do not replicate
+	// from the selected master, directly or via other monitored servers,
+	// as offline. Replication sources are resolved by Master_Server_Id
+	// (Master_UUID on MySQL). Orphaned slaves are always reported in
+	// Topology and logged.
+	DemoteOrphanedSlaves bool
+
+	// PreferDirectSlaves enables selecting slaves replicating directly
+	// from master over replicas of intermediate masters. Cumulative
+	// replication delay along the chain is always checked against
+	// MaxReplicationDelay.
+	PreferDirectSlaves bool
+
+	// PreferSemiSyncSlaves enables selecting slaves acknowledging
+	// semi-synchronous replication over asynchronous ones, both for
+	// Slave() and SwitchoverCandidate().
+	PreferSemiSyncSlaves bool
+
+	// Replication settings used by Switchover to point slaves to a new
+	// master. ReplicationAddrs holds "host:port" addresses of DB servers
+	// as seen by other servers, if address is missing server's
+	// report_host (or hostname) and port variables are used instead.
+	// Existing replication credentials are kept if ReplicationUser is
+	// empty.
+	ReplicationUser     string
+	ReplicationPassword string
+	ReplicationAddrs    map[*sql.DB]string
+
+	// CatchupTimeout limits Switchover waiting for the new master to
+	// apply transactions of the read-only old master, default
+	// MaxReplicationDelay if empty.
+	CatchupTimeout time.Duration
+
+	// queriers run status checks of pools identifying Handles.
+	queriers map[*sql.DB]Querier
 }
 
+// Logger is used to report warnings about detected DB servers state. It is
+// compatible with *log.Logger.
+type Logger interface {
+	Print(v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Print(v ...interface{}) {}
+
+type lastError struct {
+	err *CheckError
+	at  time.Time
+}
+
+// statusUpdate is a detected server status. Status checks set started time,
+// it is empty for overrides (switchover, fencing).
 type statusUpdate struct {
-	db     *sql.DB
-	status dbStatus
+	db      *sql.DB
+	status  dbStatus
+	started time.Time
 }
 
 // ErrNoDatabases is returned from New() if empty slice of databases are
@@ -48,10 +222,41 @@ var ErrNoDatabases = errors.New("empty database set provided")
 // This indicates a faulty topology configuration and should be treated as an error.
 var ErrMultipleMasters = errors.New("multiple database master connections found")
 
+// ErrUnknownDatabase is returned if provided database pool is not one of the
+// pools monitored by DBs.
+var ErrUnknownDatabase = errors.New("database is not monitored")
+
+// ErrNoMaster is returned if operation requires a master server but none is
+// currently detected.
+var ErrNoMaster = errors.New("no database master detected")
+
+// ErrNoSlave is returned from SlaveE if neither slave nor master servers are
+// currently detected.
+var ErrNoSlave = errors.New("no database slave detected")
+
+// ErrNoDelayedSlave is returned from DelayedE if no delayed slaves are
+// currently detected.
+var ErrNoDelayedSlave = errors.New("no delayed database slave detected")
+
+// ErrStopped is returned from operations that need status checking after Stop
+// was called.
+var ErrStopped = errors.New("database status checking is stopped")
+
+// ErrQuorumWithoutSlaveCheck is returned from New if Config.MasterQuorum is
+// enabled together with Config.SkipSlaveCheck. Replicas are not detected
+// without slave checks, so no master would ever reach a quorum.
+var ErrQuorumWithoutSlaveCheck = errors.New("master quorum requires slave checks")
+
+// ErrStaleTopology is returned from MasterE and SlaveE if selected server was
+// not successfully checked within Config.MaxTopologyAge.
+var ErrStaleTopology = errors.New("database topology is stale")
+
 // New creates a new instance of database pools checker.
 //
 // It will block until initial databases state is detected, therefore it is safe
 // to immediately query for master and slave pools after this function returns.
+// If Config.StateStore holds a saved topology it is used as initial state
+// instead.
 //
 // If dbs is empty slice it will return ErrNoDatabases error.
 func New(dbs []*sql.DB) (*DBs, error) {
@@ -60,9 +265,27 @@ func New(dbs []*sql.DB) (*DBs, error) {
 
 // NewWithConfig is same as New but allows passing a configuration struct.
 func NewWithConfig(dbs []*sql.DB, cfg Config) (*DBs, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sched := newScheduler(0)
+
+	p, err := newDBs(ctx, cancel, dbs, cfg, sched)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go sched.loop(ctx)
+	return p, nil
+}
+
+// newDBs creates DBs with status checks run by sched. Checks are stopped when
+// ctx is done, stop should cancel ctx.
+func newDBs(ctx context.Context, stop func(), dbs []*sql.DB, cfg Config, sched *scheduler) (*DBs, error) {
 	if len(dbs) == 0 {
 		return nil, ErrNoDatabases
 	}
+	if cfg.MasterQuorum && cfg.SkipSlaveCheck {
+		return nil, ErrQuorumWithoutSlaveCheck
+	}
 	if cfg.CheckInterval == 0 {
 		cfg.CheckInterval = defaultCheckInterval
 	}
@@ -72,23 +295,90 @@ func NewWithConfig(dbs []*sql.DB, cfg Config) (*DBs, error) {
 	if cfg.MaxReplicationDelay == 0 {
 		cfg.MaxReplicationDelay = defaultMaxReplicationDelay
 	}
+	if cfg.Logger == nil {
+		cfg.Logger = nopLogger{}
+	}
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = defaultHistorySize
+	}
+	if cfg.CatchupTimeout == 0 {
+		cfg.CatchupTimeout = cfg.MaxReplicationDelay
+	}
+	if cfg.MaxTopologyAge == 0 {
+		cfg.MaxTopologyAge = 10 * cfg.CheckInterval
+	}
+	if cfg.MaxStateAge == 0 {
+		cfg.MaxStateAge = defaultMaxStateAge
+	}
+	if cfg.BreakerWindow == 0 {
+		cfg.BreakerWindow = defaultBreakerWindow
+	}
+	if cfg.BreakerCooldown == 0 {
+		cfg.BreakerCooldown = cfg.CheckInterval
+	}
+	if cfg.ChangeCheckInterval > 0 && cfg.ChangeChecks == 0 {
+		cfg.ChangeChecks = defaultChangeChecks
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	checkers := make([]*checker, len(dbs))
+	for i, db := range dbs {
+		checkers[i] = newChecker(db, cfg)
+	}
 
-	state := checkBatch(dbs, cfg)
 	lastMaster := dbs[0]
+	state, saved, ok := restoreState(dbs, cfg)
+	if !ok {
+		state = checkBatch(checkers, cfg)
+	}
+	if saved != nil {
+		lastMaster = saved
+	}
 
 	p := &DBs{
-		active: makeSelection(state, lastMaster),
-		stop:   cancel,
-		config: cfg,
+		dbs:      dbs,
+		checkers: checkers,
+		state:    state,
+		fenced:   make(map[*sql.DB]time.Time),
+		errs:     make(map[*sql.DB]lastError),
+		checked:  make(map[*sql.DB]time.Time),
+		history:  newHistory(cfg.HistorySize),
+		sched:    sched,
+		stop:     stop,
+		done:     make(chan struct{}),
+		config:   cfg,
+
+		updates:   make(chan []statusUpdate),
+		overrides: make(chan []statusUpdate),
+
+		breakers:       make(map[*sql.DB]*breaker),
+		breakerChanged: make(chan struct{}, 1),
+	}
+	// duplicates are reported on the first selection update
+	p.active = makeSelection(withoutDuplicates(state, duplicateNodes(dbs, state)), lastMaster)
+	if cfg.BreakerErrors > 0 {
+		for _, db := range dbs {
+			p.breakers[db] = &breaker{}
+		}
+	}
+	if !ok {
+		// initial state was detected by checks, restored state is not
+		// confirmed until the first checks
+		now := time.Now()
+		for db, s := range state {
+			if s.role != RoleOffline {
+				p.checked[db] = now
+			}
+		}
 	}
 
 	if p.active.multipleMasters {
+		for _, c := range checkers {
+			c.close()
+		}
 		return nil, ErrMultipleMasters
 	}
 
-	go p.run(ctx, state, lastMaster)
+	go p.run(ctx, lastMaster)
 
 	return p, nil
 }
@@ -102,11 +392,19 @@ func NewWithConfig(dbs []*sql.DB, cfg Config) (*DBs, error) {
 //
 // If multiple master connections are detected a special sql.DB connection will be returned
 // which on execution will always return an error, preventing any potential data corruption.
+//
+// If master is pinned with PinMaster the pinned pool is returned regardless of
+// detected servers state.
 func (p *DBs) Master() *sql.DB {
 	p.mu.RLock()
 	active := p.active
+	pin := p.pin
 	p.mu.RUnlock()
 
+	if pin.active(time.Now()) {
+		return pin.db
+	}
+
 	if active.multipleMasters {
 		return newMultipleMasterErrConn()
 	}
@@ -118,6 +416,51 @@ func (p *DBs) Master() *sql.DB {
 	return active.lastMaster
 }
 
+// MasterE is same as Master but returns an error instead of a fallback pool.
+// It returns ErrMultipleMasters if multiple masters are detected, ErrNoMaster if
+// no master is detected and ErrStaleTopology if master was not successfully
+// checked within Config.MaxTopologyAge. Pinned master is returned without
+// checks.
+func (p *DBs) MasterE() (*sql.DB, error) {
+	p.mu.RLock()
+	active := p.active
+	pin := p.pin
+	checked := p.checked[active.master]
+	p.mu.RUnlock()
+
+	now := time.Now()
+	switch {
+	case pin.active(now):
+		return pin.db, nil
+	case active.multipleMasters:
+		return nil, ErrMultipleMasters
+	case active.master == nil:
+		return nil, ErrNoMaster
+	case now.Sub(checked) > p.config.MaxTopologyAge:
+		return nil, ErrStaleTopology
+	}
+	return active.master, nil
+}
+
+// SlaveE is same as Slave but returns an error instead of a fallback pool. It
+// returns ErrNoSlave if neither slave nor master is detected and
+// ErrStaleTopology if selected server was not successfully checked within
+// Config.MaxTopologyAge.
+func (p *DBs) SlaveE() (*sql.DB, error) {
+	p.mu.RLock()
+	active := p.active
+	checked := p.checked[active.slave]
+	p.mu.RUnlock()
+
+	switch {
+	case active.slave == nil:
+		return nil, ErrNoSlave
+	case time.Since(checked) > p.config.MaxTopologyAge:
+		return nil, ErrStaleTopology
+	}
+	return active.slave, nil
+}
+
 // Slave returns database pool attached to a server suitable to be used for
 // read-only non time sensitive queries. It tries to return slave instance with
 // the lowest delay. If no slaves are detected it returns a master DB instance.
@@ -137,6 +480,39 @@ func (p *DBs) Slave() *sql.DB {
 	return active.lastMaster
 }
 
+// Delayed returns database pool attached to a slave with deliberately delayed
+// replication (MASTER_DELAY), for example for reporting jobs. Delayed slaves are
+// never returned by Slave. If there are no delayed slaves it returns the same
+// pool as Slave.
+func (p *DBs) Delayed() *sql.DB {
+	p.mu.RLock()
+	delayed := p.active.delayed
+	p.mu.RUnlock()
+
+	if delayed != nil {
+		return delayed
+	}
+	return p.Slave()
+}
+
+// DelayedE is same as Delayed but returns ErrNoDelayedSlave if no delayed
+// slave is detected and ErrStaleTopology if it was not successfully checked
+// within Config.MaxTopologyAge.
+func (p *DBs) DelayedE() (*sql.DB, error) {
+	p.mu.RLock()
+	delayed := p.active.delayed
+	checked := p.checked[delayed]
+	p.mu.RUnlock()
+
+	switch {
+	case delayed == nil:
+		return nil, ErrNoDelayedSlave
+	case time.Since(checked) > p.config.MaxTopologyAge:
+		return nil, ErrStaleTopology
+	}
+	return delayed, nil
+}
+
 // Stop kills DB status checking go-routines. Functions to get master or slave
 // DB pools can be safely used after Stop is called. They will return last seen
 // state before Stop was called.
@@ -144,65 +520,238 @@ func (p *DBs) Stop() {
 	p.stop()
 }
 
-func (p *DBs) run(ctx context.Context, state map[*sql.DB]dbStatus, lastMaster *sql.DB) {
-	updates := make(chan statusUpdate)
-	for db := range state {
-		go checkLoop(ctx, db, updates, p.config)
+// run is the only writer of p.state, p.orphans and p.dups, it is safe to read
+// them without a lock from this go-routine.
+func (p *DBs) run(ctx context.Context, lastMaster *sql.DB) {
+	defer close(p.done)
+
+	if p.config.CheckRounds {
+		p.sched.add(ctx, &roundJob{p: p, updates: p.updates}, p.config.CheckInterval)
+	} else {
+		for _, c := range p.checkers {
+			s := newSchedule(c.db, p.config)
+			p.sched.add(ctx, &nodeJob{p: p, checker: c, sched: s, updates: p.updates}, s.next(nil))
+		}
+	}
+
+	// fenceWinner is a master published before multiple masters were
+	// detected, it is kept until multiple masters state is resolved.
+	// returned holds masters which were offline before becoming master,
+	// only those are fenced.
+	var fenceWinner *sql.DB
+	returned := make(map[*sql.DB]bool)
+
+	// noQuorum holds masters not confirmed by replicas on the last
+	// apply, used to log only newly unconfirmed masters.
+	var noQuorum map[*sql.DB]bool
+
+	// promoted holds masters selected by switchover, they are exempt from
+	// the quorum until replicas are pointed to them and confirm them, or
+	// until checks detect them in another role.
+	promoted := make(map[*sql.DB]bool)
+
+	// idConflicts holds already reported duplicate server_id values.
+	idConflicts := make(map[uint32]bool)
+
+	// overridden holds last override time of every server. Results of
+	// checks started before it are discarded, they might report state
+	// from before switchover or fencing.
+	overridden := make(map[*sql.DB]time.Time)
+
+	s := settler{window: p.config.SettleWindow}
+
+	// apply updates state and recomputes selection. Changed selection
+	// is published after it settles unless immediate is set.
+	var apply func(immediate bool, us ...statusUpdate)
+	apply = func(immediate bool, us ...statusUpdate) {
+		now := time.Now()
+
+		n := 0
+		for _, u := range us {
+			switch {
+			case u.started.IsZero():
+				overridden[u.db] = now
+				promoted[u.db] = u.status.role == RoleMaster
+			case u.started.Before(overridden[u.db]):
+				continue
+			}
+			us[n] = u
+			n++
+		}
+		us = us[:n]
+
+		prev := make([]dbStatus, len(us))
+		changed := false
+
+		p.mu.Lock()
+		for i, u := range us {
+			prev[i] = p.state[u.db]
+			p.state[u.db] = u.status
+			changed = changed || prev[i].role != u.status.role
+			if errs := u.status.errs.list(); len(errs) > 0 {
+				p.errs[u.db] = lastError{err: errs[0], at: now}
+			}
+			if u.status.role != RoleOffline {
+				p.checked[u.db] = now
+			}
+			if prev[i].role != u.status.role {
+				p.history.add(roleEvent(now, u.db, prev[i], u.status))
+			}
+		}
+		current := p.active
+		pin := p.pin
+		p.mu.Unlock()
+
+		for i, u := range us {
+			switch {
+			case u.status.role != RoleMaster:
+				delete(returned, u.db)
+			case prev[i].role != RoleMaster:
+				returned[u.db] = prev[i].role == RoleOffline
+			}
+			if pin.active(time.Now()) && pin.db == u.db && u.status.role != prev[i].role {
+				p.warnPinned(u.db, u.status.role)
+			}
+			if !prev[i].superWritable && u.status.superWritable {
+				p.config.Logger.Print("dbfailover: server ", p.name(u.db), " is read-only but writable by SUPER users, super_read_only is not set")
+			}
+			if !prev[i].semiSync.fallback() && u.status.semiSync.fallback() {
+				p.config.Logger.Print("dbfailover: master ", p.name(u.db), " semi-sync replication fell back to async")
+			}
+		}
+
+		// Pools connected to the same server are grouped, only the
+		// first one is used for selection.
+		dups := duplicateNodes(p.dbs, p.state)
+		for db, primary := range dups {
+			if _, ok := p.dups[db]; !ok {
+				p.config.Logger.Print("dbfailover: ", p.name(db), " is connected to the same server as ", p.name(primary))
+			}
+		}
+		for id := range serverIDConflicts(p.state) {
+			if !idConflicts[id] {
+				p.config.Logger.Print("dbfailover: server_id ", id, " is used by multiple servers")
+				idConflicts[id] = true
+			}
+		}
+		deduped := withoutDuplicates(p.state, dups)
+
+		var unconfirmed map[*sql.DB]bool
+		if p.config.MasterQuorum {
+			unconfirmed = quorumLost(deduped)
+			for db := range promoted {
+				switch {
+				case deduped[db].role != RoleMaster || !unconfirmed[db]:
+					delete(promoted, db)
+				default:
+					delete(unconfirmed, db)
+				}
+			}
+			for db := range unconfirmed {
+				if !noQuorum[db] {
+					p.config.Logger.Print("dbfailover: master ", p.name(db), " is not confirmed by a majority of replicas")
+				}
+			}
+			noQuorum = unconfirmed
+		}
+
+		statuses := withOffline(deduped, p.openBreakers(), unconfirmed)
+		active := makeSelection(statuses, lastMaster)
+
+		// Slaves are verified against the newly selected master, removing
+		// slaves does not change master selection.
+		orphans := orphanedSlaves(statuses, active.lastMaster)
+		for db := range orphans {
+			if !p.orphans[db] {
+				p.config.Logger.Print("dbfailover: slave ", p.name(db), " does not replicate from master ", p.name(active.lastMaster))
+			}
+		}
+		var demoted map[*sql.DB]bool
+		if p.config.DemoteOrphanedSlaves {
+			demoted = orphans
+		}
+		chains := replicationChains(statuses, active.lastMaster)
+		slaves := withOffline(statuses, demoted, laggingSlaves(chains, p.config.MaxReplicationDelay))
+		if p.config.PreferDirectSlaves {
+			slaves = withDepth(slaves, chains)
+		}
+		if p.config.PreferSemiSyncSlaves {
+			slaves = withSemiSync(slaves)
+		}
+		active = makeSelection(slaves, lastMaster)
+		if immediate {
+			s.reset()
+		} else {
+			active = s.settle(current, active, time.Now())
+		}
+
+		p.mu.Lock()
+		p.orphans = orphans
+		p.dups = dups
+		selectionChanged := active != p.active
+		if selectionChanged {
+			p.notify()
+			p.history.add(selectionEvent(now, active))
+		}
+		p.active = active
+		p.mu.Unlock()
+
+		if selectionChanged {
+			p.audit(AuditSelection, nil, nil)
+			p.notifySelection(ctx, current, active)
+		}
+
+		switch {
+		case !active.multipleMasters:
+			fenceWinner = nil
+		case !current.multipleMasters:
+			fenceWinner = current.master
+		}
+
+		if p.config.StateStore != nil && (changed || lastMaster != active.lastMaster) {
+			p.saveState(active.lastMaster)
+		}
+
+		// persist lastMaster pool for next iteration
+		lastMaster = active.lastMaster
+
+		if active.multipleMasters && p.config.FenceStaleMasters {
+			if fenced := p.fenceStaleMasters(ctx, fenceWinner, returned); len(fenced) > 0 {
+				lastMaster = fenceWinner
+				apply(true, fenced...)
+			}
+		}
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case u := <-updates:
-			state[u.db] = u.status
-			active := makeSelection(state, lastMaster)
-
-			p.mu.Lock()
-			p.active = active
-			p.mu.Unlock()
-
-			// persist lastMaster pool for next iteration
-			lastMaster = active.lastMaster
+		case us := <-p.updates:
+			apply(false, us...)
+		case us := <-p.overrides:
+			apply(true, us...)
+		case <-p.breakerChanged:
+			apply(true)
 		}
 	}
 }
 
-func checkBatch(dbs []*sql.DB, cfg Config) map[*sql.DB]dbStatus {
-	ss := make([]dbStatus, len(dbs))
+func checkBatch(cs []*checker, cfg Config) map[*sql.DB]dbStatus {
+	ss := make([]dbStatus, len(cs))
 	var wg sync.WaitGroup
-	wg.Add(len(dbs))
-	for i := range dbs {
+	wg.Add(len(cs))
+	for i := range cs {
 		go func(i int) {
 			defer wg.Done()
-			ss[i] = checkDBStatus(dbs[i], cfg)
+			ss[i] = cs[i].check(cfg)
 		}(i)
 	}
 	wg.Wait()
 
 	out := make(map[*sql.DB]dbStatus)
 	for i, s := range ss {
-		out[dbs[i]] = s
+		out[cs[i].db] = s
 	}
 	return out
 }
-
-func checkLoop(ctx context.Context, db *sql.DB, updates chan<- statusUpdate, cfg Config) {
-	t := time.NewTicker(cfg.CheckInterval)
-	defer t.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			status := checkDBStatus(db, cfg)
-			select {
-			case <-ctx.Done():
-				return
-			case updates <- statusUpdate{db: db, status: status}:
-				//OK
-			}
-		}
-	}
-}
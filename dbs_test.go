@@ -1,6 +1,7 @@
 package dbfailover
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
@@ -13,6 +14,168 @@ func TestNewEmpty(t *testing.T) {
 	}
 }
 
+func TestNewQuorumWithoutSlaveCheck(t *testing.T) {
+	_, err := NewWithConfig([]*sql.DB{{}}, Config{MasterQuorum: true, SkipSlaveCheck: true})
+	if err != ErrQuorumWithoutSlaveCheck {
+		t.Fatalf("expected %v, got %v", ErrQuorumWithoutSlaveCheck, err)
+	}
+}
+
+func TestMasterSlaveE(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	now := time.Now()
+
+	tests := []struct {
+		msg       string
+		active    selection
+		checked   map[*sql.DB]time.Time
+		master    *sql.DB
+		masterErr error
+		slave     *sql.DB
+		slaveErr  error
+	}{
+		{
+			msg:       "no servers",
+			masterErr: ErrNoMaster,
+			slaveErr:  ErrNoSlave,
+		},
+		{
+			msg:     "master and slave",
+			active:  selection{master: db1, slave: db2, lastMaster: db1},
+			checked: map[*sql.DB]time.Time{db1: now, db2: now},
+			master:  db1,
+			slave:   db2,
+		},
+		{
+			msg:       "stale master",
+			active:    selection{master: db1, slave: db2, lastMaster: db1},
+			checked:   map[*sql.DB]time.Time{db1: now.Add(-time.Hour), db2: now},
+			masterErr: ErrStaleTopology,
+			slave:     db2,
+		},
+		{
+			msg:       "multiple masters",
+			active:    selection{master: db1, slave: db1, lastMaster: db1, multipleMasters: true},
+			checked:   map[*sql.DB]time.Time{db1: now, db2: now},
+			masterErr: ErrMultipleMasters,
+			slave:     db1,
+		},
+		{
+			msg:       "slave only",
+			active:    selection{slave: db2, lastMaster: db1},
+			checked:   map[*sql.DB]time.Time{db2: now},
+			masterErr: ErrNoMaster,
+			slave:     db2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			p := &DBs{
+				active:  test.active,
+				checked: test.checked,
+				config:  Config{MaxTopologyAge: time.Minute},
+			}
+			m, err := p.MasterE()
+			if m != test.master || err != test.masterErr {
+				t.Errorf("master, expected %v, %v, got %v, %v", test.master, test.masterErr, m, err)
+			}
+			s, err := p.SlaveE()
+			if s != test.slave || err != test.slaveErr {
+				t.Errorf("slave, expected %v, %v, got %v, %v", test.slave, test.slaveErr, s, err)
+			}
+		})
+	}
+}
+
+// runDBs starts run loop of DBs monitoring servers from state without status
+// checks, results are injected with update. It is stopped on test cleanup.
+func runDBs(t *testing.T, dbs []*sql.DB, state map[*sql.DB]dbStatus, cfg Config) *DBs {
+	t.Helper()
+	if cfg.Logger == nil {
+		cfg.Logger = nopLogger{}
+	}
+	if cfg.CheckInterval == 0 {
+		cfg.CheckInterval = defaultCheckInterval
+	}
+	if cfg.MaxReplicationDelay == 0 {
+		cfg.MaxReplicationDelay = defaultMaxReplicationDelay
+	}
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = defaultHistorySize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &DBs{
+		dbs:       dbs,
+		state:     state,
+		fenced:    make(map[*sql.DB]time.Time),
+		errs:      make(map[*sql.DB]lastError),
+		checked:   make(map[*sql.DB]time.Time),
+		history:   newHistory(cfg.HistorySize),
+		active:    makeSelection(state, dbs[0]),
+		sched:     newScheduler(0),
+		stop:      cancel,
+		done:      make(chan struct{}),
+		config:    cfg,
+		updates:   make(chan []statusUpdate),
+		overrides: make(chan []statusUpdate),
+		breakers:  make(map[*sql.DB]*breaker),
+	}
+	go p.run(ctx, dbs[0])
+	t.Cleanup(func() {
+		p.Stop()
+		<-p.done
+	})
+	return p
+}
+
+// update sends status check results started now to the run loop and waits
+// until they are applied.
+func update(p *DBs, statuses map[*sql.DB]dbStatus) {
+	var us []statusUpdate
+	for db, status := range statuses {
+		us = append(us, statusUpdate{db: db, status: status, started: time.Now()})
+	}
+	p.updates <- us
+	p.updates <- nil
+}
+
+func TestDiscardChecksBeforeOverride(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	p := runDBs(t, []*sql.DB{db1, db2}, map[*sql.DB]dbStatus{
+		db1: {role: RoleMaster},
+		db2: {role: RoleSlave},
+	}, Config{})
+
+	started := time.Now()
+	p.overrides <- []statusUpdate{
+		{db: db2, status: dbStatus{role: RoleMaster}},
+		{db: db1, status: dbStatus{role: RoleOffline}},
+	}
+	p.updates <- nil
+	if m := p.Master(); m != db2 {
+		t.Fatal("expected overridden master")
+	}
+
+	// check started before switchover finishes after it
+	p.updates <- []statusUpdate{
+		{db: db1, status: dbStatus{role: RoleMaster}, started: started},
+		{db: db2, status: dbStatus{role: RoleSlave}, started: started},
+	}
+	p.updates <- nil
+	if m := p.Master(); m != db2 {
+		t.Error("check started before override was applied")
+	}
+
+	update(p, map[*sql.DB]dbStatus{db1: {role: RoleSlave}})
+	if s := p.Slave(); s != db1 {
+		t.Error("check started after override was not applied")
+	}
+}
+
 func TestFailover(t *testing.T) {
 	pool := getDockerPool(t)
 	network := getDockerNetwork(t, pool)
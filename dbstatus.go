@@ -3,28 +3,122 @@ package dbfailover
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"net"
 	"strconv"
 	"sync"
 	"time"
 )
 
-type role int
+// Role is a DB server role detected by status checks.
+type Role int
 
+// Known DB server roles.
 const (
-	roleOffline role = iota
-	roleSlave
-	roleMaster
+	RoleOffline Role = iota
+	RoleSlave
+	RoleMaster
+	RoleDelayed // slave with deliberately delayed replication
 )
 
+func (r Role) String() string {
+	switch r {
+	case RoleOffline:
+		return "offline"
+	case RoleSlave:
+		return "slave"
+	case RoleMaster:
+		return "master"
+	case RoleDelayed:
+		return "delayed"
+	}
+	return "Role(" + strconv.Itoa(int(r)) + ")"
+}
+
 type dbStatus struct {
-	role    role
+	role    Role
 	latency time.Duration
+
+	// replicating is set if any of slave threads are running, it is not
+	// used for role detection.
+	replicating bool
+
+	// degraded is set if any of custom probes marked as Degraded failed.
+	degraded bool
+
+	// superWritable is set if server is read-only but still writable by
+	// users with SUPER privilege, super_read_only is supported but not
+	// set. It is not used for role detection.
+	superWritable bool
+
+	// reason describes why the role was detected.
+	reason string
+
+	// delay is slave replication delay, it is not used for role
+	// detection. sqlDelay is configured MASTER_DELAY included in delay.
+	delay    time.Duration
+	sqlDelay time.Duration
+
+	// masterAddr is "host:port" address of the replication source, empty
+	// if replication is not configured.
+	masterAddr string
+
+	// serverID, serverUUID, hostname and port identify the server,
+	// masterServerID and masterUUID identify its replication source.
+	// UUIDs are available on MySQL only.
+	serverID       uint32
+	serverUUID     string
+	hostname       string
+	port           int
+	masterServerID uint32
+	masterUUID     string
+
+	// savedIdentity is server identity restored from saved state, it is
+	// used until the server is checked.
+	savedIdentity string
+
+	// galera is set for nodes with wsrep enabled, Galera cluster nodes
+	// share the same server_id.
+	galera bool
+
+	// semiSync is semi-synchronous replication state, it is not used for
+	// role detection.
+	semiSync semiSyncStatus
+
+	// async is set for slaves not acknowledging semi-sync replication, it
+	// is set only for selection with Config.PreferSemiSyncSlaves.
+	async bool
+
+	// channels lists all replication connections, pointer keeps dbStatus
+	// comparable. It is nil if server is not a slave.
+	channels *[]ChannelStatus
+
+	// depth is replication depth used to rank slaves, it is set only for
+	// selection with Config.PreferDirectSlaves.
+	depth int
+
+	// addr is "host:port" address of the server as seen by replicas, it
+	// is detected for masters with Config.MasterQuorum only.
+	addr string
+
+	errs checkErrors
 }
 
 type readOnlyStatus struct {
-	online   bool
-	readOnly bool
-	latency  time.Duration
+	online bool
+
+	// readOnly is set if any of read_only, super_read_only (MySQL) or
+	// innodb_read_only flags is set.
+	readOnly       bool
+	superReadOnly  bool
+	innodbReadOnly bool
+
+	// superWritable is set if read_only is set but super_read_only, while
+	// supported, is not.
+	superWritable bool
+
+	latency time.Duration
+	err     *CheckError
 }
 
 type slaveStatus struct {
@@ -33,13 +127,36 @@ type slaveStatus struct {
 	runningIO  bool
 	runningSQL bool
 	delay      time.Duration
+	sqlDelay   time.Duration // configured MASTER_DELAY
+	masterAddr string
+	masterID   uint32
+	masterUUID string
+	channels   []ChannelStatus
 	latency    time.Duration
+	err        *CheckError
+}
+
+// ChannelStatus is replication state of a single MariaDB multi-source
+// connection or MySQL replication channel.
+type ChannelStatus struct {
+	Name    string // empty for the default connection
+	Running bool   // both IO and SQL threads are running
+	Delay   time.Duration
+}
+
+type identityStatus struct {
+	serverID   uint32
+	serverUUID string
+	hostname   string
+	port       int
+	err        *CheckError
 }
 
 type wsrepStatus struct {
 	online  bool
 	ready   bool
 	latency time.Duration
+	err     *CheckError
 }
 
 func maxTime(ts ...time.Duration) time.Duration {
@@ -52,130 +169,266 @@ func maxTime(ts ...time.Duration) time.Duration {
 	return max
 }
 
-func mergeStatus(ss slaveStatus, rs readOnlyStatus, ws wsrepStatus, maxReplicationDelay time.Duration) dbStatus {
-	role := roleOffline
+// mergeStatus detects server role from check results. Servers with
+// innodb_read_only and without replication are slaves only if storageReplicas
+// is set.
+func mergeStatus(ss slaveStatus, rs readOnlyStatus, ws wsrepStatus, ps []probeStatus, maxReplicationDelay time.Duration, storageReplicas bool) dbStatus {
+	role := RoleOffline
+	reason := ""
 
 	switch {
 	case !rs.online:
 		// skip checking if any of the checks failed
-		role = roleOffline
+		role = RoleOffline
+		reason = "read_only check failed"
+	case !ss.online && ss.err != nil && ss.err.outage():
+		// slave status failed because server is not reachable, not
+		// because of missing permissions.
+		role = RoleOffline
+		reason = "slave check failed"
 	case rs.readOnly && !ss.online:
 		// slave status might fail beacause of missing REPLICTION CLIENT
 		// permission, server is read-only.
-		role = roleSlave
+		role = RoleSlave
+		reason = "read-only, slave status unavailable"
 	case !rs.readOnly && !ss.online:
 		// slave status might fail beacause of missing REPLICTION CLIENT
 		// permission, server is writable.
-		role = roleMaster
+		role = RoleMaster
+		reason = "writable, slave status unavailable"
 	case rs.readOnly && ss.configured && ss.runningIO && ss.runningSQL:
 		// Perfect slave, read-only and all slave threads running
-		role = roleSlave
+		role = RoleSlave
+		reason = "read-only, replication running"
 	case rs.readOnly && ss.configured && ss.runningIO && !ss.runningSQL:
 		// Slave is configured but replication have stopped
 		// replication delay measuremet is not available
-		role = roleOffline
+		role = RoleOffline
+		reason = "read-only, replication SQL thread stopped"
 	case rs.readOnly && ss.configured && !ss.runningIO:
 		// Slave is configured but not started or stopped already
-		role = roleOffline
+		role = RoleOffline
+		reason = "read-only, replication stopped"
+	case storageReplicas && rs.innodbReadOnly && !ss.configured:
+		// Storage engine is read-only without binlog replication, for
+		// example Aurora reader replicating on storage level.
+		role = RoleSlave
+		reason = "innodb read-only without replication"
 	case rs.readOnly && !ss.configured:
 		// Server is read-only without slave replication configuration,
 		// might be miss-configuration or master is being demoted to
 		// slave.
-		role = roleOffline
+		role = RoleOffline
+		reason = "read-only without replication"
 	case !rs.readOnly && ss.configured && ss.runningIO && ss.runningSQL:
 		// Fully working slave but without read-only flag. Dangerous but
 		// valid configuration.
-		role = roleSlave
+		role = RoleSlave
+		reason = "writable, replication running"
 	case !rs.readOnly && ss.configured && ss.runningIO && !ss.runningSQL:
 		// Faulty slave and without read-only flag. Extremely dangerous
 		// tread as offline.
-		role = roleOffline
+		role = RoleOffline
+		reason = "writable, replication SQL thread stopped"
 	case !rs.readOnly && ss.configured && !ss.runningIO:
 		// No read-only flag, slave is configured but not running, most
 		// likely old slave newly promoted to master. This happens
 		// after SLAVE RESET.
-		role = roleMaster
+		role = RoleMaster
+		reason = "writable, replication stopped"
 	case !rs.readOnly && !ss.configured:
 		// Perfect master, not read-only, no slave configuration
-		role = roleMaster
+		role = RoleMaster
+		reason = "writable without replication"
+	}
+
+	// Deliberately delayed slaves are not used as regular slaves, their
+	// delay is allowed to exceed the limit by the configured delay.
+	if role == RoleSlave && ss.sqlDelay > 0 {
+		role = RoleDelayed
+		reason = "delayed replication"
+		maxReplicationDelay += ss.sqlDelay
 	}
 
 	// Make sure slave server is not lagging behind
-	if role == roleSlave && ss.delay > maxReplicationDelay {
-		role = roleOffline
+	if (role == RoleSlave || role == RoleDelayed) && ss.delay > maxReplicationDelay {
+		role = RoleOffline
+		reason = "replication delay above limit"
 	}
 
 	// Make sure we will not use failed galera cluster nodes
 	if ws.online && !ws.ready {
-		role = roleOffline
+		role = RoleOffline
+		reason = "galera node not ready"
+	}
+
+	// Custom probes failures
+	var (
+		degraded bool
+		probeErr *CheckError
+	)
+	for _, p := range ps {
+		if p.err == nil {
+			continue
+		}
+		if probeErr == nil {
+			probeErr = p.err
+		}
+		if p.degraded {
+			degraded = true
+		} else {
+			role = RoleOffline
+			reason = "probe " + p.err.Probe + " failed"
+		}
 	}
 
 	return dbStatus{
-		role:    role,
-		latency: maxTime(rs.latency, ss.latency),
+		role:     role,
+		latency:  maxTime(rs.latency, ss.latency),
+		degraded: degraded,
+		reason:   reason,
+		errs: checkErrors{
+			CheckReadOnly: rs.err,
+			CheckSlave:    ss.err,
+			CheckWsrep:    ws.err,
+			CheckProbe:    probeErr,
+		},
 	}
 }
 
-func checkDBStatus(db *sql.DB, cfg Config) dbStatus {
+// Querier is used to run status checks, it is implemented by both *sql.DB and
+// *sql.Conn.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// dialect holds SQL dialect differences detected on a server, it is kept by
+// checker between checks.
+type dialect struct {
+	// noAllSlaves is set for servers rejecting SHOW ALL SLAVES STATUS
+	// (MySQL).
+	noAllSlaves bool
+}
+
+// checkDBStatus runs all enabled checks on db. If sequential is set checks are
+// run one after another, this is required for a single connection.
+func checkDBStatus(db Querier, cfg Config, sequential bool, dl *dialect) dbStatus {
 	var (
 		wg sync.WaitGroup
 
 		ss slaveStatus
 		rs readOnlyStatus
 		ws wsrepStatus
+		is identityStatus
+		sy semiSyncStatus
+		ps = make([]probeStatus, len(cfg.Probes))
 	)
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		rs = checkReadOnlyStatus(db, cfg.CheckTimeout)
-	}()
-	if !cfg.SkipSlaveCheck {
+	run := func(f func()) {
+		if sequential {
+			f()
+			return
+		}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			ss = checkSlaveStatus(db, cfg.CheckTimeout)
+			f()
 		}()
 	}
+
+	run(func() {
+		rs = checkReadOnlyStatus(db, cfg.CheckTimeout)
+	})
+	run(func() {
+		is = checkIdentity(db, cfg.CheckTimeout)
+	})
+	if !cfg.SkipSlaveCheck {
+		run(func() {
+			ss = checkSlaveStatus(db, cfg.CheckTimeout, cfg.ReplicationChannels, dl)
+		})
+	}
+	if !cfg.SkipSemiSyncCheck {
+		run(func() {
+			sy = checkSemiSyncStatus(db, cfg.CheckTimeout)
+		})
+	}
 	if !cfg.SkipGaleraCheck {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+		run(func() {
 			ws = checkWsrepStatus(db, cfg.CheckTimeout)
-		}()
+		})
+	}
+
+	for i := range cfg.Probes {
+		run(func() {
+			ps[i] = runProbe(db, cfg.Probes[i], cfg.CheckTimeout)
+		})
 	}
 
 	wg.Wait()
 
-	return mergeStatus(ss, rs, ws, cfg.MaxReplicationDelay)
+	status := mergeStatus(ss, rs, ws, ps, cfg.MaxReplicationDelay, cfg.StorageReplicas)
+	status.superWritable = rs.superWritable
+	status.galera = ws.online
+	status.replicating = ss.runningIO || ss.runningSQL
+	status.delay = ss.delay
+	status.sqlDelay = ss.sqlDelay
+	status.masterAddr = ss.masterAddr
+	status.masterServerID = ss.masterID
+	status.masterUUID = ss.masterUUID
+	if ss.channels != nil {
+		status.channels = &ss.channels
+	}
+	status.serverID = is.serverID
+	status.serverUUID = is.serverUUID
+	status.hostname = is.hostname
+	status.port = is.port
+	status.errs[CheckIdentity] = is.err
+	status.errs[CheckSemiSync] = sy.err
+	sy.err = nil
+	status.semiSync = sy
+	return status
 }
 
-func checkReadOnlyStatus(db *sql.DB, timeout time.Duration) readOnlyStatus {
+// checkReadOnlyStatus reads all read-only flags in a single query. Flags not
+// supported by the server are treated as disabled.
+func checkReadOnlyStatus(db Querier, timeout time.Duration) readOnlyStatus {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var (
-		key string
-		val string
-	)
 	start := time.Now()
-	err := db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'read_only'").Scan(&key, &val)
+	rows, err := db.QueryContext(ctx, "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('read_only', 'super_read_only', 'innodb_read_only')")
 	d := time.Since(start)
+	if err != nil {
+		return readOnlyStatus{online: false, latency: d, err: newCheckError(CheckReadOnly, err)}
+	}
+	defer rows.Close()
 
+	all, err := scanRows(rows)
 	if err != nil {
-		return readOnlyStatus{
-			online:  false,
-			latency: d,
-		}
+		return readOnlyStatus{online: false, latency: d, err: newCheckError(CheckReadOnly, err)}
 	}
-	return readOnlyStatus{
-		online:   true,
-		readOnly: val == "ON",
-		latency:  d,
+	vals := make(map[string]string)
+	for _, row := range all {
+		vals[row["Variable_name"]] = row["Value"]
+	}
+	if _, ok := vals["read_only"]; !ok {
+		return readOnlyStatus{online: false, latency: d, err: newCheckError(CheckReadOnly, sql.ErrNoRows)}
+	}
+
+	rs := readOnlyStatus{
+		online:         true,
+		superReadOnly:  vals["super_read_only"] == "ON",
+		innodbReadOnly: vals["innodb_read_only"] == "ON",
+		latency:        d,
 	}
+	rs.readOnly = vals["read_only"] == "ON" || rs.superReadOnly || rs.innodbReadOnly
+	_, hasSuperReadOnly := vals["super_read_only"]
+	rs.superWritable = vals["read_only"] == "ON" && hasSuperReadOnly && !rs.superReadOnly && !rs.innodbReadOnly
+	return rs
 }
 
-func checkWsrepStatus(db *sql.DB, timeout time.Duration) wsrepStatus {
+func checkWsrepStatus(db Querier, timeout time.Duration) wsrepStatus {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -187,7 +440,15 @@ func checkWsrepStatus(db *sql.DB, timeout time.Duration) wsrepStatus {
 	err := db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'wsrep_on'").Scan(&key, &val)
 	d := time.Since(start)
 
-	if err != nil || val != "ON" {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return wsrepStatus{
+			online:  false,
+			latency: d,
+			err:     newCheckError(CheckWsrep, err),
+		}
+	}
+	if val != "ON" {
+		// wsrep_on variable is missing or disabled, not a galera node
 		return wsrepStatus{
 			online:  false,
 			latency: d,
@@ -195,30 +456,49 @@ func checkWsrepStatus(db *sql.DB, timeout time.Duration) wsrepStatus {
 	}
 
 	err = db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'wsrep_ready'").Scan(&key, &val)
-	return wsrepStatus{
+	ws := wsrepStatus{
 		online:  true,
 		ready:   err == nil && val == "ON",
 		latency: d,
 	}
+	if err != nil {
+		ws.err = newCheckError(CheckWsrep, err)
+	}
+	return ws
 }
 
-func checkSlaveStatus(db *sql.DB, timeout time.Duration) slaveStatus {
+// checkSlaveStatus checks all replication connections (MariaDB multi-source)
+// or channels (MySQL). If channels are given only those are required to be
+// running, otherwise all of them are required.
+func checkSlaveStatus(db Querier, timeout time.Duration, channels []string, dl *dialect) slaveStatus {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	start := time.Now()
-	rows, err := db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	var (
+		rows  *sql.Rows
+		err   error
+		start = time.Now()
+	)
+	if !dl.noAllSlaves {
+		rows, err = db.QueryContext(ctx, "SHOW ALL SLAVES STATUS")
+		dl.noAllSlaves = syntaxError(err)
+	}
+	if dl.noAllSlaves {
+		// MySQL lists all channels in SHOW SLAVE STATUS
+		start = time.Now()
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	}
 	d := time.Since(start)
 	if err != nil {
-		return slaveStatus{online: false, latency: d}
+		return slaveStatus{online: false, latency: d, err: newCheckError(CheckSlave, err)}
 	}
 	defer rows.Close()
-	cols, err := rows.Columns()
+
+	all, err := scanRows(rows)
 	if err != nil {
-		return slaveStatus{online: false, latency: d}
+		return slaveStatus{online: false, latency: d, err: newCheckError(CheckSlave, err)}
 	}
-
-	if !rows.Next() {
+	if len(all) == 0 {
 		// Empty response, server is not a slave
 		return slaveStatus{
 			online:  true,
@@ -226,38 +506,162 @@ func checkSlaveStatus(db *sql.DB, timeout time.Duration) slaveStatus {
 		}
 	}
 
+	ss, err := mergeChannels(all, channels)
+	if err != nil {
+		return slaveStatus{
+			online:  false,
+			latency: d,
+			err:     &CheckError{Check: CheckSlave, Kind: ErrorParse, Err: err},
+		}
+	}
+	ss.latency = d
+	return ss
+}
+
+// mergeChannels merges status rows of replication connections into a single
+// slave status, see checkSlaveStatus.
+func mergeChannels(all []map[string]string, channels []string) (slaveStatus, error) {
+	byName := make(map[string]map[string]string)
+	for _, vals := range all {
+		byName[channelName(vals)] = vals
+	}
+	var required []map[string]string
+	if len(channels) == 0 {
+		required = all
+	}
+	for _, name := range channels {
+		// missing channel is reported as not running
+		required = append(required, byName[name])
+	}
+
+	ss := slaveStatus{
+		online:     true,
+		configured: true,
+		runningIO:  true,
+		runningSQL: true,
+	}
+	for _, vals := range required {
+		delay, err := parseDelay(vals)
+		if err != nil {
+			return slaveStatus{}, err
+		}
+		ss.runningIO = ss.runningIO && vals["Slave_IO_Running"] == "Yes"
+		ss.runningSQL = ss.runningSQL && vals["Slave_SQL_Running"] == "Yes"
+		ss.delay = max(ss.delay, delay)
+		// SQL_Delay is missing on old servers, treated as not delayed
+		sqlDelay, _ := strconv.Atoi(vals["SQL_Delay"])
+		ss.sqlDelay = max(ss.sqlDelay, time.Duration(sqlDelay)*time.Second)
+	}
+	for _, vals := range all {
+		delay, _ := parseDelay(vals)
+		ss.channels = append(ss.channels, ChannelStatus{
+			Name:    channelName(vals),
+			Running: vals["Slave_IO_Running"] == "Yes" && vals["Slave_SQL_Running"] == "Yes",
+			Delay:   delay,
+		})
+	}
+
+	// Replication source is taken from the default connection if it
+	// exists, multi-source servers are identified by the first one.
+	src, ok := byName[""]
+	if !ok {
+		src = all[0]
+	}
+	ss.masterAddr = net.JoinHostPort(src["Master_Host"], src["Master_Port"])
+	ss.masterID = parseServerID(src["Master_Server_Id"])
+	ss.masterUUID = src["Master_UUID"]
+	return ss, nil
+}
+
+// scanRows reads all rows as column name to value maps.
+func scanRows(rows *sql.Rows) ([]map[string]string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []map[string]string
 	strs := make([]sql.NullString, len(cols))
 	strps := make([]interface{}, len(cols))
 	for i := range strs {
 		strps[i] = &strs[i]
 	}
-	if err := rows.Scan(strps...); err != nil {
-		return slaveStatus{online: false, latency: d}
+	for rows.Next() {
+		if err := rows.Scan(strps...); err != nil {
+			return nil, err
+		}
+		vals := make(map[string]string)
+		for i := range cols {
+			vals[cols[i]] = strs[i].String
+		}
+		out = append(out, vals)
 	}
-	if err := rows.Err(); err != nil {
-		return slaveStatus{online: false, latency: d}
+	return out, rows.Err()
+}
+
+// channelName returns MariaDB connection name or MySQL channel name, empty
+// for the default connection.
+func channelName(vals map[string]string) string {
+	if name, ok := vals["Connection_name"]; ok {
+		return name
 	}
+	return vals["Channel_Name"]
+}
 
-	vals := make(map[string]string)
-	for i := range cols {
-		vals[cols[i]] = strs[i].String
+// parseDelay returns replication delay, unknown delay (replication is not
+// running) is reported as a week.
+func parseDelay(vals map[string]string) (time.Duration, error) {
+	val := vals["Seconds_Behind_Master"]
+	if val == "" {
+		return 7 * 24 * time.Hour, nil
+	}
+	sec, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(sec) * time.Second, nil
+}
+
+// checkIdentity detects server_id, server_uuid (MySQL only), hostname and port
+// variables. Failures are reported but do not affect the detected role.
+func checkIdentity(db Querier, timeout time.Duration) identityStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('server_id', 'server_uuid', 'hostname', 'port')")
+	if err != nil {
+		return identityStatus{err: newCheckError(CheckIdentity, err)}
 	}
+	defer rows.Close()
 
-	delay := 7 * 24 * time.Hour
-	if val := vals["Seconds_Behind_Master"]; val != "" {
-		sec, err := strconv.Atoi(val)
-		if err != nil {
-			return slaveStatus{online: false, latency: d}
+	var is identityStatus
+	for rows.Next() {
+		var key, val string
+		if err := rows.Scan(&key, &val); err != nil {
+			return identityStatus{err: newCheckError(CheckIdentity, err)}
 		}
-		delay = time.Duration(sec) * time.Second
+		switch key {
+		case "server_id":
+			is.serverID = parseServerID(val)
+		case "server_uuid":
+			is.serverUUID = val
+		case "hostname":
+			is.hostname = val
+		case "port":
+			is.port, _ = strconv.Atoi(val)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return identityStatus{err: newCheckError(CheckIdentity, err)}
 	}
+	return is
+}
 
-	return slaveStatus{
-		online:     true,
-		configured: true,
-		runningIO:  vals["Slave_IO_Running"] == "Yes",
-		runningSQL: vals["Slave_SQL_Running"] == "Yes",
-		delay:      delay,
-		latency:    d,
+// parseServerID returns 0 (unknown) for empty or invalid values.
+func parseServerID(val string) uint32 {
+	id, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		return 0
 	}
+	return uint32(id)
 }
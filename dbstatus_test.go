@@ -1,18 +1,26 @@
 package dbfailover
 
 import (
+	"context"
 	"database/sql"
+	"reflect"
 	"testing"
 	"time"
 )
 
 func TestMergeStatus(t *testing.T) {
+	accessDeniedErr := &CheckError{Check: CheckSlave, Kind: ErrorAccessDenied}
+	timeoutErr := &CheckError{Check: CheckSlave, Kind: ErrorTimeout}
+	probeErr := &CheckError{Check: CheckProbe, Probe: "canary"}
+
 	tests := []struct {
-		msg  string
-		rs   readOnlyStatus
-		ss   slaveStatus
-		ws   wsrepStatus
-		want dbStatus
+		msg             string
+		rs              readOnlyStatus
+		ss              slaveStatus
+		ws              wsrepStatus
+		ps              []probeStatus
+		storageReplicas bool
+		want            dbStatus
 	}{
 		{
 			msg: "read-only check failed",
@@ -23,7 +31,8 @@ func TestMergeStatus(t *testing.T) {
 				online: true,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "read_only check failed",
 			},
 		},
 		{
@@ -36,7 +45,8 @@ func TestMergeStatus(t *testing.T) {
 				online: false,
 			},
 			want: dbStatus{
-				role: roleMaster,
+				role:   RoleMaster,
+				reason: "writable, slave status unavailable",
 			},
 		},
 		{
@@ -49,7 +59,40 @@ func TestMergeStatus(t *testing.T) {
 				online: false,
 			},
 			want: dbStatus{
-				role: roleSlave,
+				role:   RoleSlave,
+				reason: "read-only, slave status unavailable",
+			},
+		},
+		{
+			msg: "slave check access denied, writable server",
+			rs: readOnlyStatus{
+				online:   true,
+				readOnly: false,
+			},
+			ss: slaveStatus{
+				online: false,
+				err:    accessDeniedErr,
+			},
+			want: dbStatus{
+				role:   RoleMaster,
+				reason: "writable, slave status unavailable",
+				errs:   checkErrors{CheckSlave: accessDeniedErr},
+			},
+		},
+		{
+			msg: "slave check timeout, writable server",
+			rs: readOnlyStatus{
+				online:   true,
+				readOnly: false,
+			},
+			ss: slaveStatus{
+				online: false,
+				err:    timeoutErr,
+			},
+			want: dbStatus{
+				role:   RoleOffline,
+				reason: "slave check failed",
+				errs:   checkErrors{CheckSlave: timeoutErr},
 			},
 		},
 		{
@@ -63,7 +106,8 @@ func TestMergeStatus(t *testing.T) {
 				configured: false,
 			},
 			want: dbStatus{
-				role: roleMaster,
+				role:   RoleMaster,
+				reason: "writable without replication",
 			},
 		},
 		{
@@ -81,7 +125,8 @@ func TestMergeStatus(t *testing.T) {
 				ready:  false,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "galera node not ready",
 			},
 		},
 		{
@@ -99,7 +144,8 @@ func TestMergeStatus(t *testing.T) {
 				ready:  true,
 			},
 			want: dbStatus{
-				role: roleMaster,
+				role:   RoleMaster,
+				reason: "writable without replication",
 			},
 		},
 		{
@@ -115,7 +161,8 @@ func TestMergeStatus(t *testing.T) {
 				runningSQL: false,
 			},
 			want: dbStatus{
-				role: roleMaster,
+				role:   RoleMaster,
+				reason: "writable, replication stopped",
 			},
 		},
 		{
@@ -131,7 +178,8 @@ func TestMergeStatus(t *testing.T) {
 				runningSQL: false,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "writable, replication SQL thread stopped",
 			},
 		},
 		{
@@ -147,7 +195,8 @@ func TestMergeStatus(t *testing.T) {
 				runningSQL: true,
 			},
 			want: dbStatus{
-				role: roleSlave,
+				role:   RoleSlave,
+				reason: "writable, replication running",
 			},
 		},
 		{
@@ -163,7 +212,8 @@ func TestMergeStatus(t *testing.T) {
 				runningSQL: true,
 			},
 			want: dbStatus{
-				role: roleSlave,
+				role:   RoleSlave,
+				reason: "read-only, replication running",
 			},
 		},
 		{
@@ -183,7 +233,8 @@ func TestMergeStatus(t *testing.T) {
 				ready:  false,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "galera node not ready",
 			},
 		},
 		{
@@ -203,7 +254,8 @@ func TestMergeStatus(t *testing.T) {
 				ready:  true,
 			},
 			want: dbStatus{
-				role: roleSlave,
+				role:   RoleSlave,
+				reason: "read-only, replication running",
 			},
 		},
 		{
@@ -220,7 +272,92 @@ func TestMergeStatus(t *testing.T) {
 				delay:      time.Hour,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "replication delay above limit",
+			},
+		},
+		{
+			msg: "innodb read-only without replication",
+			rs: readOnlyStatus{
+				online:         true,
+				readOnly:       true,
+				innodbReadOnly: true,
+			},
+			ss: slaveStatus{
+				online: true,
+			},
+			storageReplicas: true,
+			want: dbStatus{
+				role:   RoleSlave,
+				reason: "innodb read-only without replication",
+			},
+		},
+		{
+			msg: "innodb read-only without storage replicas",
+			rs: readOnlyStatus{
+				online:         true,
+				readOnly:       true,
+				innodbReadOnly: true,
+			},
+			ss: slaveStatus{
+				online: true,
+			},
+			want: dbStatus{
+				role:   RoleOffline,
+				reason: "read-only without replication",
+			},
+		},
+		{
+			msg: "super read-only without replication",
+			rs: readOnlyStatus{
+				online:        true,
+				readOnly:      true,
+				superReadOnly: true,
+			},
+			ss: slaveStatus{
+				online: true,
+			},
+			want: dbStatus{
+				role:   RoleOffline,
+				reason: "read-only without replication",
+			},
+		},
+		{
+			msg: "delayed slave",
+			rs: readOnlyStatus{
+				online:   true,
+				readOnly: true,
+			},
+			ss: slaveStatus{
+				online:     true,
+				configured: true,
+				runningIO:  true,
+				runningSQL: true,
+				delay:      time.Hour,
+				sqlDelay:   time.Hour,
+			},
+			want: dbStatus{
+				role:   RoleDelayed,
+				reason: "delayed replication",
+			},
+		},
+		{
+			msg: "delayed slave, lagging behind configured delay",
+			rs: readOnlyStatus{
+				online:   true,
+				readOnly: true,
+			},
+			ss: slaveStatus{
+				online:     true,
+				configured: true,
+				runningIO:  true,
+				runningSQL: true,
+				delay:      2 * time.Hour,
+				sqlDelay:   time.Hour,
+			},
+			want: dbStatus{
+				role:   RoleOffline,
+				reason: "replication delay above limit",
 			},
 		},
 		{
@@ -236,7 +373,8 @@ func TestMergeStatus(t *testing.T) {
 				runningSQL: false,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "read-only, replication SQL thread stopped",
 			},
 		},
 		{
@@ -252,7 +390,8 @@ func TestMergeStatus(t *testing.T) {
 				runningSQL: false,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "read-only, replication stopped",
 			},
 		},
 		{
@@ -266,7 +405,52 @@ func TestMergeStatus(t *testing.T) {
 				configured: false,
 			},
 			want: dbStatus{
-				role: roleOffline,
+				role:   RoleOffline,
+				reason: "read-only without replication",
+			},
+		},
+		{
+			msg: "perfect slave, failed probe",
+			rs: readOnlyStatus{
+				online:   true,
+				readOnly: true,
+			},
+			ss: slaveStatus{
+				online:     true,
+				configured: true,
+				runningIO:  true,
+				runningSQL: true,
+			},
+			ps: []probeStatus{
+				{},
+				{err: probeErr},
+			},
+			want: dbStatus{
+				role:   RoleOffline,
+				reason: "probe canary failed",
+				errs:   checkErrors{CheckProbe: probeErr},
+			},
+		},
+		{
+			msg: "perfect slave, failed degraded probe",
+			rs: readOnlyStatus{
+				online:   true,
+				readOnly: true,
+			},
+			ss: slaveStatus{
+				online:     true,
+				configured: true,
+				runningIO:  true,
+				runningSQL: true,
+			},
+			ps: []probeStatus{
+				{degraded: true, err: probeErr},
+			},
+			want: dbStatus{
+				role:     RoleSlave,
+				reason:   "read-only, replication running",
+				degraded: true,
+				errs:     checkErrors{CheckProbe: probeErr},
 			},
 		},
 		{
@@ -280,7 +464,8 @@ func TestMergeStatus(t *testing.T) {
 				latency: 2 * time.Second,
 			},
 			want: dbStatus{
-				role:    roleMaster,
+				role:    RoleMaster,
+				reason:  "writable without replication",
 				latency: 2 * time.Second,
 			},
 		},
@@ -288,7 +473,7 @@ func TestMergeStatus(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.msg, func(t *testing.T) {
-			got := mergeStatus(test.ss, test.rs, test.ws, defaultMaxReplicationDelay)
+			got := mergeStatus(test.ss, test.rs, test.ws, test.ps, defaultMaxReplicationDelay, test.storageReplicas)
 			if got != test.want {
 				t.Errorf("rs: %v, ss: %v, expected: %v, got: %v", test.rs, test.ss, test.want, got)
 			}
@@ -378,7 +563,7 @@ func TestSlaveStatus(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.msg, func(t *testing.T) {
-			status := checkSlaveStatus(test.db, defaultCheckTimeout)
+			status := checkSlaveStatus(test.db, defaultCheckTimeout, nil, &dialect{})
 			if status.online != test.online {
 				t.Errorf("online, expected %v, got %v", test.online, status.online)
 			}
@@ -521,3 +706,113 @@ func TestCheckWsrepStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeChannels(t *testing.T) {
+	row := func(name, io, sql, delay string) map[string]string {
+		return map[string]string{
+			"Connection_name":       name,
+			"Slave_IO_Running":      io,
+			"Slave_SQL_Running":     sql,
+			"Seconds_Behind_Master": delay,
+			"Master_Host":           "master-" + name,
+			"Master_Port":           "3306",
+			"Master_Server_Id":      "1",
+		}
+	}
+
+	tests := []struct {
+		msg        string
+		rows       []map[string]string
+		channels   []string
+		runningIO  bool
+		runningSQL bool
+		delay      time.Duration
+		masterAddr string
+	}{
+		{
+			msg:        "single connection",
+			rows:       []map[string]string{row("", "Yes", "Yes", "3")},
+			runningIO:  true,
+			runningSQL: true,
+			delay:      3 * time.Second,
+			masterAddr: "master-:3306",
+		},
+		{
+			msg:        "all connections required",
+			rows:       []map[string]string{row("a", "Yes", "Yes", "1"), row("b", "Yes", "No", "")},
+			runningIO:  true,
+			runningSQL: false,
+			delay:      7 * 24 * time.Hour,
+			masterAddr: "master-a:3306",
+		},
+		{
+			msg:        "stopped connection not required",
+			rows:       []map[string]string{row("a", "Yes", "Yes", "1"), row("b", "No", "No", ""), row("", "Yes", "Yes", "5")},
+			channels:   []string{"a", ""},
+			runningIO:  true,
+			runningSQL: true,
+			delay:      5 * time.Second,
+			masterAddr: "master-:3306",
+		},
+		{
+			msg:        "missing required connection",
+			rows:       []map[string]string{row("a", "Yes", "Yes", "1")},
+			channels:   []string{"a", "b"},
+			runningIO:  false,
+			runningSQL: false,
+			delay:      7 * 24 * time.Hour,
+			masterAddr: "master-a:3306",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			ss, err := mergeChannels(test.rows, test.channels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ss.runningIO != test.runningIO || ss.runningSQL != test.runningSQL {
+				t.Errorf("running, expected %v/%v, got %v/%v", test.runningIO, test.runningSQL, ss.runningIO, ss.runningSQL)
+			}
+			if ss.delay != test.delay {
+				t.Errorf("delay, expected %v, got %v", test.delay, ss.delay)
+			}
+			if ss.masterAddr != test.masterAddr {
+				t.Errorf("master address, expected %v, got %v", test.masterAddr, ss.masterAddr)
+			}
+			if len(ss.channels) != len(test.rows) {
+				t.Errorf("channels, expected %d, got %d", len(test.rows), len(ss.channels))
+			}
+		})
+	}
+
+	if _, err := mergeChannels([]map[string]string{row("", "Yes", "Yes", "x")}, nil); err == nil {
+		t.Error("expected delay parse error")
+	}
+}
+
+type queryLog struct {
+	Querier
+	queries []string
+}
+
+func (q *queryLog) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	q.queries = append(q.queries, query)
+	return q.Querier.QueryContext(ctx, query, args...)
+}
+
+func TestCheckSlaveStatusDialect(t *testing.T) {
+	q := &queryLog{Querier: openProbeDB(t, "mysql")}
+	var dl dialect
+
+	for i := 0; i < 2; i++ {
+		ss := checkSlaveStatus(q, defaultCheckTimeout, nil, &dl)
+		if !ss.online || ss.configured || ss.err != nil {
+			t.Errorf("check %d, unexpected status %+v", i, ss)
+		}
+	}
+	want := []string{"SHOW ALL SLAVES STATUS", "SHOW SLAVE STATUS", "SHOW SLAVE STATUS"}
+	if !reflect.DeepEqual(q.queries, want) {
+		t.Errorf("expected queries %v, got %v", want, q.queries)
+	}
+}
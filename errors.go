@@ -0,0 +1,199 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Check identifies a status check.
+type Check int
+
+// Status checks run on every server.
+const (
+	CheckConnect Check = iota
+	CheckReadOnly
+	CheckSlave
+	CheckWsrep
+	CheckProbe // custom probe from Config.Probes
+	CheckIdentity
+	CheckSemiSync
+	numChecks
+)
+
+func (c Check) String() string {
+	switch c {
+	case CheckConnect:
+		return "connect"
+	case CheckReadOnly:
+		return "read_only"
+	case CheckSlave:
+		return "slave"
+	case CheckWsrep:
+		return "wsrep"
+	case CheckProbe:
+		return "probe"
+	case CheckIdentity:
+		return "identity"
+	case CheckSemiSync:
+		return "semi_sync"
+	}
+	return "Check(" + strconv.Itoa(int(c)) + ")"
+}
+
+// ErrorKind is a class of a status check failure.
+type ErrorKind int
+
+// Known status check failure classes.
+const (
+	ErrorOther ErrorKind = iota
+	ErrorTimeout
+	ErrorConnRefused
+	ErrorAccessDenied
+	ErrorTooManyConnections
+	ErrorParse
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorOther:
+		return "other"
+	case ErrorTimeout:
+		return "timeout"
+	case ErrorConnRefused:
+		return "connection refused"
+	case ErrorAccessDenied:
+		return "access denied"
+	case ErrorTooManyConnections:
+		return "too many connections"
+	case ErrorParse:
+		return "parse error"
+	}
+	return "ErrorKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// CheckError is a classified status check failure.
+type CheckError struct {
+	Check Check
+	Probe string // probe name if Check is CheckProbe
+	Kind  ErrorKind
+	Err   error
+}
+
+func (e *CheckError) Error() string {
+	name := e.Check.String()
+	if e.Probe != "" {
+		name += " " + e.Probe
+	}
+	return name + " check: " + e.Kind.String() + ": " + e.Err.Error()
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// outage reports if error means server is not reachable, as opposed to errors
+// caused by missing permissions or unexpected responses.
+func (e *CheckError) outage() bool {
+	return outage(e.Kind)
+}
+
+func outage(k ErrorKind) bool {
+	switch k {
+	case ErrorTimeout, ErrorConnRefused, ErrorTooManyConnections:
+		return true
+	}
+	return false
+}
+
+// checkErrors holds errors of failed checks indexed by Check.
+type checkErrors [numChecks]*CheckError
+
+func (es checkErrors) list() []*CheckError {
+	var out []*CheckError
+	for _, e := range es {
+		if e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// brokenConn reports if any check failed with an error leaving connection in
+// unknown state: broken, closed, invalidated by the driver or timed out mid
+// query.
+func (es checkErrors) brokenConn() bool {
+	for _, e := range es.list() {
+		switch {
+		case e.Kind == ErrorTimeout,
+			errors.Is(e.Err, driver.ErrBadConn),
+			errors.Is(e.Err, mysql.ErrInvalidConn),
+			errors.Is(e.Err, sql.ErrConnDone):
+			return true
+		}
+	}
+	return false
+}
+
+func newCheckError(check Check, err error) *CheckError {
+	return &CheckError{
+		Check: check,
+		Kind:  classifyError(err),
+		Err:   err,
+	}
+}
+
+// MySQL server error numbers.
+const (
+	erDBAccessDenied         = 1044
+	erAccessDenied           = 1045
+	erTableAccessDenied      = 1142
+	erSpecificAccessDenied   = 1227
+	erConCount               = 1040
+	erTooManyUserConnections = 1203
+	erParseError             = 1064
+	erUnknownSystemVariable  = 1193
+)
+
+// syntaxError reports if query was rejected by the server parser, for example
+// MariaDB specific statement sent to MySQL.
+func syntaxError(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == erParseError
+}
+
+// unknownVariable reports if server does not support a system variable, for
+// example MySQL specific variable set on MariaDB.
+func unknownVariable(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == erUnknownSystemVariable
+}
+
+func classifyError(err error) ErrorKind {
+	var (
+		myErr  *mysql.MySQLError
+		netErr net.Error
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnRefused
+	case errors.As(err, &myErr):
+		switch myErr.Number {
+		case erDBAccessDenied, erAccessDenied, erTableAccessDenied, erSpecificAccessDenied:
+			return ErrorAccessDenied
+		case erConCount, erTooManyUserConnections:
+			return ErrorTooManyConnections
+		}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	}
+	return ErrorOther
+}
@@ -0,0 +1,110 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		msg  string
+		err  error
+		want ErrorKind
+	}{
+		{
+			msg:  "unknown",
+			err:  errors.New("boom"),
+			want: ErrorOther,
+		},
+		{
+			msg:  "context timeout",
+			err:  fmt.Errorf("query: %w", context.DeadlineExceeded),
+			want: ErrorTimeout,
+		},
+		{
+			msg:  "connection refused",
+			err:  &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED},
+			want: ErrorConnRefused,
+		},
+		{
+			msg:  "missing REPLICATION CLIENT",
+			err:  &mysql.MySQLError{Number: 1227, Message: "Access denied"},
+			want: ErrorAccessDenied,
+		},
+		{
+			msg:  "wrong password",
+			err:  &mysql.MySQLError{Number: 1045, Message: "Access denied"},
+			want: ErrorAccessDenied,
+		},
+		{
+			msg:  "too many connections",
+			err:  &mysql.MySQLError{Number: 1040, Message: "Too many connections"},
+			want: ErrorTooManyConnections,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			got := classifyError(test.err)
+			if got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckErrorsBrokenConn(t *testing.T) {
+	tests := []struct {
+		msg  string
+		err  error
+		want bool
+	}{
+		{
+			msg: "no errors",
+		},
+		{
+			msg: "access denied",
+			err: &mysql.MySQLError{Number: 1227, Message: "Access denied"},
+		},
+		{
+			msg:  "bad connection",
+			err:  fmt.Errorf("query: %w", driver.ErrBadConn),
+			want: true,
+		},
+		{
+			msg:  "invalid connection",
+			err:  mysql.ErrInvalidConn,
+			want: true,
+		},
+		{
+			msg:  "closed connection",
+			err:  sql.ErrConnDone,
+			want: true,
+		},
+		{
+			msg:  "timeout",
+			err:  context.DeadlineExceeded,
+			want: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			var es checkErrors
+			if test.err != nil {
+				es[CheckSemiSync] = newCheckError(CheckSemiSync, test.err)
+			}
+			if got := es.brokenConn(); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
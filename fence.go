@@ -0,0 +1,67 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// staleMasters returns writable servers other than winner if winner is still
+// detected as master. Only former masters coming back from offline (returned)
+// are stale, nothing is fenced if any of the other masters is replicating or
+// was promoted from slave.
+func staleMasters(statuses map[*sql.DB]dbStatus, winner *sql.DB, returned map[*sql.DB]bool) []*sql.DB {
+	if winner == nil || statuses[winner].role != RoleMaster {
+		return nil
+	}
+
+	var stale []*sql.DB
+	for db, status := range statuses {
+		if db == winner || status.role != RoleMaster {
+			continue
+		}
+		if status.replicating || !returned[db] {
+			return nil
+		}
+		stale = append(stale, db)
+	}
+	return stale
+}
+
+// fenceStaleMasters sets read_only (super_read_only on MySQL) flag on stale
+// masters. It returns status updates for successfully fenced servers.
+func (p *DBs) fenceStaleMasters(ctx context.Context, winner *sql.DB, returned map[*sql.DB]bool) []statusUpdate {
+	var us []statusUpdate
+	for _, db := range staleMasters(withoutDuplicates(p.state, p.dups), winner, returned) {
+		if err := fence(ctx, db, p.config.CheckTimeout); err != nil {
+			p.config.Logger.Print("dbfailover: fencing stale master ", p.name(db), ": ", err)
+			p.audit(AuditFence, db, err)
+			continue
+		}
+		p.audit(AuditFence, db, nil)
+		p.config.Logger.Print("dbfailover: stale master ", p.name(db), " fenced with read_only flag")
+
+		p.mu.Lock()
+		p.fenced[db] = time.Now()
+		p.mu.Unlock()
+
+		// Fenced server is read-only and not replicating
+		us = append(us, statusUpdate{
+			db:     db,
+			status: dbStatus{role: RoleOffline, latency: p.state[db].latency, reason: "fenced as stale master"},
+		})
+	}
+	return us
+}
+
+func fence(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// super_read_only also blocks SUPER users, it implies read_only
+	_, err := db.ExecContext(ctx, "SET GLOBAL super_read_only = 1")
+	if unknownVariable(err) {
+		_, err = db.ExecContext(ctx, "SET GLOBAL read_only = 1")
+	}
+	return err
+}
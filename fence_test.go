@@ -0,0 +1,146 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStaleMasters(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	db3 := &sql.DB{}
+
+	tests := []struct {
+		msg      string
+		states   map[*sql.DB]dbStatus
+		winner   *sql.DB
+		returned map[*sql.DB]bool
+		want     []*sql.DB
+	}{
+		{
+			msg: "no winner",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleMaster},
+			},
+		},
+		{
+			msg: "winner is not master anymore",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleOffline},
+				db2: {role: RoleMaster},
+			},
+			winner: db1,
+		},
+		{
+			msg: "stale master",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleMaster},
+				db3: {role: RoleSlave, replicating: true},
+			},
+			winner:   db1,
+			returned: map[*sql.DB]bool{db2: true},
+			want:     []*sql.DB{db2},
+		},
+		{
+			msg: "promoted slave",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleMaster},
+			},
+			winner: db1,
+		},
+		{
+			msg: "returned and promoted masters",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleMaster},
+				db3: {role: RoleMaster},
+			},
+			winner:   db1,
+			returned: map[*sql.DB]bool{db2: true},
+		},
+		{
+			msg: "replicating master",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleMaster, replicating: true},
+			},
+			winner:   db1,
+			returned: map[*sql.DB]bool{db2: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			got := staleMasters(test.states, test.winner, test.returned)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFenceOnlyReturningMasters(t *testing.T) {
+	tests := []struct {
+		msg   string
+		steps []map[int]Role
+		fence bool
+	}{
+		{
+			msg: "old master returns before promoted slave is checked",
+			steps: []map[int]Role{
+				{0: RoleOffline},
+				{0: RoleMaster},
+				{1: RoleMaster},
+			},
+			fence: false,
+		},
+		{
+			msg: "old master returns after failover",
+			steps: []map[int]Role{
+				{0: RoleOffline},
+				{1: RoleMaster},
+				{0: RoleMaster},
+			},
+			fence: true,
+		},
+		{
+			msg: "slave promoted while master is writable",
+			steps: []map[int]Role{
+				{1: RoleMaster},
+			},
+			fence: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			dbs := []*sql.DB{startOfflineInstance(t), startOfflineInstance(t)}
+			sink := &memorySink{}
+			p := runDBs(t, dbs, map[*sql.DB]dbStatus{
+				dbs[0]: {role: RoleMaster},
+				dbs[1]: {role: RoleSlave},
+			}, Config{FenceStaleMasters: true, Audit: sink, CheckTimeout: 100 * time.Millisecond})
+
+			for _, step := range test.steps {
+				statuses := make(map[*sql.DB]dbStatus)
+				for i, role := range step {
+					statuses[dbs[i]] = dbStatus{role: role}
+				}
+				update(p, statuses)
+			}
+
+			fenced := false
+			for _, r := range sink.list() {
+				fenced = fenced || r.Action == AuditFence
+			}
+			if fenced != test.fence {
+				t.Errorf("fencing attempted %v, expected %v", fenced, test.fence)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Adapter converts caller's DB handles (for example *sqlx.DB) to queriers
+// status checks are run through.
+type Adapter[H comparable] interface {
+	// Querier returns a querier status checks of handle h are run through.
+	Querier(h H) Querier
+}
+
+// FuncAdapter is an Adapter calling f.
+type FuncAdapter[H comparable] func(h H) Querier
+
+// Querier implements Adapter.
+func (f FuncAdapter[H]) Querier(h H) Querier {
+	return f(h)
+}
+
+// Handles is DBs returning caller's own handle type from Master() and Slave().
+// Status checks and selection are done by the underlying DBs, every handle is
+// identified there by a placeholder pool which fails all queries. Operations
+// running statements through pools (Switchover, FenceStaleMasters) are not
+// supported. Config fields keyed by pools (Names, MonitorDBs,
+// ReplicationAddrs) are not used, servers are named by their position.
+type Handles[H comparable] struct {
+	dbs     *DBs
+	handles map[*sql.DB]H
+	pools   map[H]*sql.DB
+}
+
+// NewHandles creates a monitor of handles hs, status checks are run through
+// queriers returned by adapter. It blocks until initial state is detected,
+// same as NewWithConfig.
+func NewHandles[H comparable](hs []H, adapter Adapter[H], cfg Config) (*Handles[H], error) {
+	h := newHandles(hs)
+	pools := make([]*sql.DB, len(hs))
+	cfg.queriers = make(map[*sql.DB]Querier, len(hs))
+	for i, handle := range hs {
+		pools[i] = h.pools[handle]
+		cfg.queriers[pools[i]] = adapter.Querier(handle)
+	}
+
+	dbs, err := NewWithConfig(pools, cfg)
+	if err != nil {
+		h.close()
+		return nil, err
+	}
+	h.dbs = dbs
+	return h, nil
+}
+
+func newHandles[H comparable](hs []H) *Handles[H] {
+	h := &Handles[H]{
+		handles: make(map[*sql.DB]H, len(hs)),
+		pools:   make(map[H]*sql.DB, len(hs)),
+	}
+	for _, handle := range hs {
+		if _, ok := h.pools[handle]; ok {
+			continue
+		}
+		db, _ := sql.Open("dbfailover_err_driver", "pool identifies a handle, it can not run queries")
+		h.pools[handle] = db
+		h.handles[db] = handle
+	}
+	return h
+}
+
+// Pool returns a placeholder pool identifying handle in the underlying DBs,
+// for example for PinMaster. It returns nil for unknown handles.
+func (h *Handles[H]) Pool(handle H) *sql.DB {
+	return h.pools[handle]
+}
+
+// Handle returns a handle identified by a placeholder pool db. Zero value is
+// returned for unknown pools.
+func (h *Handles[H]) Handle(db *sql.DB) H {
+	return h.handles[db]
+}
+
+// Master returns a handle of the currently active master, see DBs.Master. Zero
+// value is returned if multiple masters are detected, use MasterE to get the
+// error.
+func (h *Handles[H]) Master() H {
+	return h.Handle(h.dbs.Master())
+}
+
+// Slave returns a handle of the server suitable for read-only queries, see
+// DBs.Slave.
+func (h *Handles[H]) Slave() H {
+	return h.Handle(h.dbs.Slave())
+}
+
+// Delayed returns a handle of a slave with deliberately delayed replication,
+// see DBs.Delayed.
+func (h *Handles[H]) Delayed() H {
+	return h.Handle(h.dbs.Delayed())
+}
+
+// MasterE returns a handle of the currently active master or an error, see
+// DBs.MasterE.
+func (h *Handles[H]) MasterE() (H, error) {
+	return h.handleE(h.dbs.MasterE())
+}
+
+// SlaveE returns a handle of the server suitable for read-only queries or an
+// error, see DBs.SlaveE.
+func (h *Handles[H]) SlaveE() (H, error) {
+	return h.handleE(h.dbs.SlaveE())
+}
+
+// DelayedE returns a handle of a delayed slave or an error, see
+// DBs.DelayedE.
+func (h *Handles[H]) DelayedE() (H, error) {
+	return h.handleE(h.dbs.DelayedE())
+}
+
+// WaitForMaster blocks until a single writable master is detected and returns
+// its handle, see DBs.WaitForMaster.
+func (h *Handles[H]) WaitForMaster(ctx context.Context) (H, error) {
+	return h.handleE(h.dbs.WaitForMaster(ctx))
+}
+
+func (h *Handles[H]) handleE(db *sql.DB, err error) (H, error) {
+	if err != nil {
+		var zero H
+		return zero, err
+	}
+	return h.Handle(db), nil
+}
+
+// Topology returns detected state of all monitored servers, see
+// DBs.Topology. Servers are identified by placeholder pools, use Handle to get
+// their handles.
+func (h *Handles[H]) Topology() Topology {
+	return h.dbs.Topology()
+}
+
+// DBs returns the underlying monitor, it allows using operations working with
+// pools like PinMaster.
+func (h *Handles[H]) DBs() *DBs {
+	return h.dbs
+}
+
+// Stop kills status checking go-routines, see DBs.Stop.
+func (h *Handles[H]) Stop() {
+	h.dbs.Stop()
+	h.close()
+}
+
+func (h *Handles[H]) close() {
+	for db := range h.handles {
+		_ = db.Close()
+	}
+}
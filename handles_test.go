@@ -0,0 +1,65 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testHandle struct {
+	db     *sql.DB
+	checks atomic.Int32
+}
+
+func (h *testHandle) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	h.checks.Add(1)
+	return h.db.QueryContext(ctx, query, args...)
+}
+
+func (h *testHandle) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	h.checks.Add(1)
+	return h.db.QueryRowContext(ctx, query, args...)
+}
+
+func TestHandles(t *testing.T) {
+	a := &testHandle{db: startOfflineInstance(t)}
+	b := &testHandle{db: startOfflineInstance(t)}
+
+	adapter := FuncAdapter[*testHandle](func(h *testHandle) Querier { return h })
+	h, err := NewHandles([]*testHandle{a, b}, adapter, Config{CheckTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("creating handles: %v", err)
+	}
+	defer h.Stop()
+
+	if a.checks.Load() == 0 || b.checks.Load() == 0 {
+		t.Errorf("checks are not run through handles")
+	}
+
+	// all servers are offline, last seen master is returned
+	if m := h.Master(); m != a {
+		t.Errorf("master handle does not match")
+	}
+	if s := h.Slave(); s != a {
+		t.Errorf("slave handle does not match")
+	}
+	if d := h.Delayed(); d != a {
+		t.Errorf("delayed handle does not match")
+	}
+	if _, err := h.MasterE(); !errors.Is(err, ErrNoMaster) {
+		t.Errorf("expected %v, got %v", ErrNoMaster, err)
+	}
+
+	if h.Handle(h.Pool(b)) != b {
+		t.Errorf("pool does not identify handle")
+	}
+	if w := h.Handle(newMultipleMasterErrConn()); w != nil {
+		t.Errorf("expected zero handle for unknown pool, got %v", w)
+	}
+	if _, err := h.Pool(a).Exec("SELECT 1"); err == nil {
+		t.Errorf("placeholder pool runs queries")
+	}
+}
@@ -0,0 +1,123 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"time"
+)
+
+const defaultHistorySize = 100
+
+// EventType is a kind of recorded history event.
+type EventType int
+
+// History event types.
+const (
+	// EventRole is recorded when server role changes.
+	EventRole EventType = iota
+	// EventSelection is recorded when master/slave selection changes.
+	EventSelection
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventRole:
+		return "role"
+	case EventSelection:
+		return "selection"
+	}
+	return "unknown"
+}
+
+// Event is a single server role or selection change. Events recorded in one
+// check round share the same Time, Seq increases by one for every recorded
+// event and can be used to find events not seen yet.
+type Event struct {
+	Seq  uint64
+	Time time.Time
+	Type EventType
+
+	// Server role change, set for EventRole
+	DB      *sql.DB
+	OldRole Role
+	NewRole Role
+	Reason  string
+	Latency time.Duration
+	Delay   time.Duration
+
+	// New selection, set for EventSelection
+	Master          *sql.DB
+	Slave           *sql.DB
+	LastMaster      *sql.DB
+	MultipleMasters bool
+}
+
+// history is a bounded ring of events.
+type history struct {
+	events []Event
+	next   int
+	full   bool
+	seq    uint64
+}
+
+func newHistory(size int) *history {
+	if size < 0 {
+		size = 0
+	}
+	return &history{events: make([]Event, size)}
+}
+
+func (h *history) add(e Event) {
+	if len(h.events) == 0 {
+		return
+	}
+	h.seq++
+	e.Seq = h.seq
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	h.full = h.full || h.next == 0
+}
+
+// list returns events oldest first.
+func (h *history) list() []Event {
+	if !h.full {
+		return append([]Event(nil), h.events[:h.next]...)
+	}
+	out := make([]Event, 0, len(h.events))
+	out = append(out, h.events[h.next:]...)
+	return append(out, h.events[:h.next]...)
+}
+
+func roleEvent(now time.Time, db *sql.DB, prev, status dbStatus) Event {
+	return Event{
+		Time:    now,
+		Type:    EventRole,
+		DB:      db,
+		OldRole: prev.role,
+		NewRole: status.role,
+		Reason:  status.reason,
+		Latency: status.latency,
+		Delay:   status.delay,
+	}
+}
+
+func selectionEvent(now time.Time, s selection) Event {
+	return Event{
+		Time:            now,
+		Type:            EventSelection,
+		Master:          s.master,
+		Slave:           s.slave,
+		LastMaster:      s.lastMaster,
+		MultipleMasters: s.multipleMasters,
+	}
+}
+
+// History returns recorded server role and selection changes, oldest first. At
+// most Config.HistorySize last events are kept.
+func (p *DBs) History() []Event {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.history == nil {
+		return nil
+	}
+	return p.history.list()
+}
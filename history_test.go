@@ -0,0 +1,45 @@
+package dbfailover
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Now()
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+
+	h := newHistory(3)
+	if got := h.list(); len(got) != 0 {
+		t.Fatalf("expected empty history, got %v", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		h.add(Event{Time: at(i)})
+	}
+	if got := h.list(); len(got) != 2 || got[0].Time != at(0) || got[1].Time != at(1) {
+		t.Errorf("unexpected partial history %v", got)
+	}
+
+	for i := 2; i < 5; i++ {
+		h.add(Event{Time: at(i)})
+	}
+	got := h.list()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(got))
+	}
+	for i, e := range got {
+		if e.Time != at(i+2) {
+			t.Errorf("event %d, expected time %v, got %v", i, at(i+2), e.Time)
+		}
+		if e.Seq != uint64(i+3) {
+			t.Errorf("event %d, expected seq %d, got %d", i, i+3, e.Seq)
+		}
+	}
+
+	disabled := newHistory(-1)
+	disabled.add(Event{Time: at(0)})
+	if got := disabled.list(); len(got) != 0 {
+		t.Errorf("disabled history recorded events %v", got)
+	}
+}
@@ -0,0 +1,80 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"net"
+	"strconv"
+)
+
+// identity returns a key identifying the physical server, it is empty if
+// server identity is unknown. MariaDB servers without server_uuid are
+// identified by hostname, port and server_id. Restored servers not checked yet
+// are identified by the saved identity.
+func (s dbStatus) identity() string {
+	if s.serverUUID != "" {
+		return s.serverUUID
+	}
+	if s.hostname == "" || s.serverID == 0 {
+		return s.savedIdentity
+	}
+	return net.JoinHostPort(s.hostname, strconv.Itoa(s.port)) + "/" + strconv.FormatUint(uint64(s.serverID), 10)
+}
+
+// duplicateNodes finds pools connected to the same server, for example via a
+// virtual IP and a direct address. It returns aliases mapped to the pool
+// listed first in dbs. Offline servers are not grouped, so an alias takes over
+// when the first pool becomes unreachable.
+func duplicateNodes(dbs []*sql.DB, statuses map[*sql.DB]dbStatus) map[*sql.DB]*sql.DB {
+	first := make(map[string]*sql.DB)
+	dups := make(map[*sql.DB]*sql.DB)
+	for _, db := range dbs {
+		s := statuses[db]
+		id := s.identity()
+		if s.role == RoleOffline || id == "" {
+			continue
+		}
+		if primary, ok := first[id]; ok {
+			dups[db] = primary
+			continue
+		}
+		first[id] = db
+	}
+	return dups
+}
+
+// withoutDuplicates returns statuses without aliases of other pools. The
+// original map is returned if there are no aliases.
+func withoutDuplicates(statuses map[*sql.DB]dbStatus, dups map[*sql.DB]*sql.DB) map[*sql.DB]dbStatus {
+	if len(dups) == 0 {
+		return statuses
+	}
+	out := make(map[*sql.DB]dbStatus, len(statuses))
+	for db, status := range statuses {
+		if _, ok := dups[db]; !ok {
+			out[db] = status
+		}
+	}
+	return out
+}
+
+// serverIDConflicts returns server_id values shared by different servers.
+// Replication silently skips events of a server with the same server_id, it is
+// a configuration error. Galera nodes are skipped, wsrep_gtid_mode requires
+// all nodes of a cluster to share the same server_id. Nodes are detected as
+// Galera by the wsrep check, Galera clusters with Config.SkipGaleraCheck are
+// reported.
+func serverIDConflicts(statuses map[*sql.DB]dbStatus) map[uint32]bool {
+	ids := make(map[uint32]string)
+	conflicts := make(map[uint32]bool)
+	for _, s := range statuses {
+		id := s.identity()
+		if s.serverID == 0 || id == "" || s.galera {
+			continue
+		}
+		if other, ok := ids[s.serverID]; ok && other != id {
+			conflicts[s.serverID] = true
+		}
+		ids[s.serverID] = id
+	}
+	return conflicts
+}
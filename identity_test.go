@@ -0,0 +1,71 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestDuplicateNodes(t *testing.T) {
+	vip := &sql.DB{}
+	direct := &sql.DB{}
+	slave := &sql.DB{}
+
+	master := dbStatus{role: RoleMaster, serverID: 1, hostname: "db1", port: 3306}
+	statuses := map[*sql.DB]dbStatus{
+		vip:    master,
+		direct: master,
+		slave:  {role: RoleSlave, serverID: 2, hostname: "db2", port: 3306},
+	}
+	dbs := []*sql.DB{vip, direct, slave}
+
+	dups := duplicateNodes(dbs, statuses)
+	if len(dups) != 1 || dups[direct] != vip {
+		t.Fatalf("expected direct pool to be duplicate of vip, got %v", dups)
+	}
+	s := makeSelection(withoutDuplicates(statuses, dups), vip)
+	if s.multipleMasters || s.master != vip || s.slave != slave {
+		t.Errorf("unexpected selection %+v", s)
+	}
+
+	// alias takes over when the first pool is unreachable
+	statuses[vip] = dbStatus{role: RoleOffline}
+	if dups := duplicateNodes(dbs, statuses); len(dups) != 0 {
+		t.Errorf("expected no duplicates, got %v", dups)
+	}
+
+	// servers with unknown identity are never grouped
+	statuses = map[*sql.DB]dbStatus{
+		vip:    {role: RoleMaster},
+		direct: {role: RoleMaster},
+	}
+	if dups := duplicateNodes(dbs, statuses); len(dups) != 0 {
+		t.Errorf("expected no duplicates, got %v", dups)
+	}
+}
+
+func TestServerIDConflicts(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	db3 := &sql.DB{}
+	db4 := &sql.DB{}
+
+	statuses := map[*sql.DB]dbStatus{
+		db1: {serverID: 1, hostname: "db1", port: 3306},
+		db2: {serverID: 1, hostname: "db1", port: 3306}, // same server
+		db3: {serverID: 2, hostname: "db2", port: 3306},
+		db4: {serverID: 2, hostname: "db3", port: 3306},
+	}
+	conflicts := serverIDConflicts(statuses)
+	if len(conflicts) != 1 || !conflicts[2] {
+		t.Errorf("expected server_id 2 conflict, got %v", conflicts)
+	}
+
+	galera := map[*sql.DB]dbStatus{
+		db1: {serverID: 3, hostname: "node1", port: 3306, galera: true},
+		db2: {serverID: 3, hostname: "node2", port: 3306, galera: true},
+		db3: {serverID: 3, hostname: "node3", port: 3306, galera: true},
+	}
+	if conflicts := serverIDConflicts(galera); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts of galera nodes, got %v", conflicts)
+	}
+}
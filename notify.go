@@ -0,0 +1,202 @@
+package dbfailover
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Notification events.
+const (
+	NotifyMasterChanged   = "master_changed"
+	NotifyMultipleMasters = "multiple_masters"
+)
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+)
+
+// Notification describes a master change or multiple masters detection.
+// Servers are identified by Config.Names.
+type Notification struct {
+	Time            time.Time `json:"time"`
+	Event           string    `json:"event"`
+	OldMaster       string    `json:"old_master,omitempty"`
+	NewMaster       string    `json:"new_master,omitempty"`
+	MultipleMasters bool      `json:"multiple_masters"`
+}
+
+// Notifier delivers notifications, for example to on-call paging systems.
+// Notify is called from a separate go-routine for every notification, ctx is
+// cancelled when DBs is stopped.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// notifySelection sends notifications about changes between published
+// selections. Master changes while multiple masters are detected are not
+// reported, a single master change from the last master before multiple
+// masters is sent once they are resolved. It must be called from the run
+// go-routine.
+func (p *DBs) notifySelection(ctx context.Context, prev, active selection) {
+	if len(p.config.Notifiers) == 0 {
+		return
+	}
+
+	n := Notification{
+		Time:            time.Now(),
+		OldMaster:       p.nameOrEmpty(prev.lastMaster),
+		NewMaster:       p.nameOrEmpty(active.lastMaster),
+		MultipleMasters: active.multipleMasters,
+	}
+	switch {
+	case active.multipleMasters && !prev.multipleMasters:
+		p.multiple = prev.lastMaster
+		n.Event = NotifyMultipleMasters
+	case active.multipleMasters:
+		return
+	case prev.multipleMasters:
+		n.Event = NotifyMasterChanged
+		n.OldMaster = p.nameOrEmpty(p.multiple)
+	case active.lastMaster != prev.lastMaster:
+		n.Event = NotifyMasterChanged
+	default:
+		return
+	}
+
+	for _, nt := range p.config.Notifiers {
+		go func(nt Notifier) {
+			if err := nt.Notify(ctx, n); err != nil {
+				p.config.Logger.Print("dbfailover: sending ", n.Event, " notification: ", err)
+			}
+		}(nt)
+	}
+}
+
+func (p *DBs) nameOrEmpty(db *sql.DB) string {
+	if db == nil {
+		return ""
+	}
+	return p.name(db)
+}
+
+// Webhook is a Notifier posting notifications as JSON to URL. Failed requests
+// (network errors, 429 and 5xx responses) are retried with exponential
+// backoff. If Secret is set request body is signed with HMAC-SHA256, hex
+// encoded signature is sent in X-Dbfailover-Signature header as
+// "sha256=<signature>".
+type Webhook struct {
+	URL     string
+	Secret  string
+	Retries int           // default 3 if empty
+	Backoff time.Duration // delay before the first retry, default 1 sec if empty
+	Client  *http.Client  // http.DefaultClient if empty
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	retries := w.Retries
+	if retries == 0 {
+		retries = defaultWebhookRetries
+	}
+	backoff := w.Backoff
+	if backoff == 0 {
+		backoff = defaultWebhookBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff << attempt):
+		}
+	}
+}
+
+// post sends a single request, it returns true if request should be retried.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set("X-Dbfailover-Signature", "sha256="+sign(w.Secret, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return false, nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Command is a Notifier executing a command for every notification. The
+// notification is passed in environment variables DBFAILOVER_EVENT,
+// DBFAILOVER_TIME (RFC 3339), DBFAILOVER_OLD_MASTER, DBFAILOVER_NEW_MASTER and
+// DBFAILOVER_MULTIPLE_MASTERS ("true" or "false").
+type Command struct {
+	Path    string
+	Args    []string
+	Timeout time.Duration // no timeout if empty
+}
+
+// Notify implements Notifier.
+func (c *Command) Notify(ctx context.Context, n Notification) error {
+	if c.Timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Env = append(os.Environ(),
+		"DBFAILOVER_EVENT="+n.Event,
+		"DBFAILOVER_TIME="+n.Time.Format(time.RFC3339),
+		"DBFAILOVER_OLD_MASTER="+n.OldMaster,
+		"DBFAILOVER_NEW_MASTER="+n.NewMaster,
+		"DBFAILOVER_MULTIPLE_MASTERS="+strconv.FormatBool(n.MultipleMasters),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("running %s: %w: %s", c.Path, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package dbfailover
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryNotifier struct {
+	mu sync.Mutex
+	ns []Notification
+}
+
+func (m *memoryNotifier) Notify(ctx context.Context, n Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ns = append(m.ns, n)
+	return nil
+}
+
+func (m *memoryNotifier) events() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []string
+	for _, n := range m.ns {
+		events = append(events, n.Event+" "+n.OldMaster+">"+n.NewMaster)
+	}
+	return events
+}
+
+func TestNotifySelection(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	n := &memoryNotifier{}
+	p := &DBs{
+		dbs: []*sql.DB{db1, db2},
+		config: Config{
+			Logger:    nopLogger{},
+			Notifiers: []Notifier{n},
+			Names:     map[*sql.DB]string{db1: "a", db2: "b"},
+		},
+	}
+
+	steps := []selection{
+		{master: db1, lastMaster: db1, slave: db2},
+		{master: nil, lastMaster: db1, slave: db2},
+		{master: db2, lastMaster: db2, slave: db2},
+		{master: db2, lastMaster: db2, slave: db2, multipleMasters: true},
+		{master: db2, lastMaster: db2, slave: db2, multipleMasters: true},
+		{master: db1, lastMaster: db1, slave: db1, multipleMasters: true},
+		{master: db2, lastMaster: db2, slave: db2, multipleMasters: true},
+		{master: db1, lastMaster: db1, slave: db2},
+	}
+	var prev selection
+	for _, s := range steps {
+		p.notifySelection(context.Background(), prev, s)
+		prev = s
+	}
+
+	want := []string{
+		NotifyMasterChanged + " >a",
+		NotifyMasterChanged + " a>b",
+		NotifyMultipleMasters + " b>b",
+		NotifyMasterChanged + " b>a",
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(n.events()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	got := n.events()
+	if len(got) != len(want) {
+		t.Fatalf("got %v notifications, want %v", got, want)
+	}
+	// notifications are delivered concurrently, order is not guaranteed
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			found = found || g == w
+		}
+		if !found {
+			t.Errorf("notification %q not found in %v", w, got)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		body     []byte
+		sig      string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		sig = r.Header.Get("X-Dbfailover-Signature")
+	}))
+	defer srv.Close()
+
+	wh := &Webhook{URL: srv.URL, Secret: "secret", Backoff: time.Millisecond}
+	n := Notification{Event: NotifyMasterChanged, OldMaster: "a", NewMaster: "b"}
+	if err := wh.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+	if want := "sha256=" + sign("secret", body); !hmac.Equal([]byte(sig), []byte(want)) {
+		t.Errorf("got signature %q, want %q", sig, want)
+	}
+	var got Notification
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != n {
+		t.Errorf("got %+v, want %+v", got, n)
+	}
+
+	// client errors are not retried
+	requests = 0
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	if err := wh.Notify(context.Background(), n); err == nil {
+		t.Error("expected error")
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	c := &Command{
+		Path: "/bin/sh",
+		Args: []string{"-c", `echo "$DBFAILOVER_EVENT $DBFAILOVER_OLD_MASTER $DBFAILOVER_NEW_MASTER $DBFAILOVER_MULTIPLE_MASTERS" > ` + out},
+	}
+	n := Notification{Time: time.Now(), Event: NotifyMasterChanged, OldMaster: "a", NewMaster: "b"}
+	if err := c.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(b)), "master_changed a b false"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	c = &Command{Path: "/bin/sh", Args: []string{"-c", "echo failed; exit 1"}}
+	if err := c.Notify(context.Background(), n); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("got %v, want command error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"time"
+)
+
+type pin struct {
+	db    *sql.DB
+	until time.Time
+}
+
+func (p pin) active(now time.Time) bool {
+	return p.db != nil && (p.until.IsZero() || now.Before(p.until))
+}
+
+// PinMaster forces Master() to return db for the ttl duration regardless of
+// detected servers state. Zero or negative ttl pins master until UnpinMaster is
+// called. Server roles are still checked and reported by Topology(), a warning
+// is logged if pinned server is detected to be read-only or offline.
+//
+// If db is not one of the monitored pools ErrUnknownDatabase is returned.
+func (p *DBs) PinMaster(db *sql.DB, ttl time.Duration) error {
+	if !p.known(db) {
+		return ErrUnknownDatabase
+	}
+
+	np := pin{db: db}
+	if ttl > 0 {
+		np.until = time.Now().Add(ttl)
+	}
+
+	p.mu.Lock()
+	p.pin = np
+	p.notify()
+	status := p.state[db]
+	p.mu.Unlock()
+
+	p.audit(AuditPin, db, nil)
+	if status.role != RoleMaster {
+		p.warnPinned(db, status.role)
+	}
+	return nil
+}
+
+// UnpinMaster removes master override set by PinMaster.
+func (p *DBs) UnpinMaster() {
+	p.mu.Lock()
+	unpinned := p.pin.db
+	p.pin = pin{}
+	p.notify()
+	p.mu.Unlock()
+
+	p.audit(AuditUnpin, unpinned, nil)
+}
+
+func (p *DBs) warnPinned(db *sql.DB, r Role) {
+	p.config.Logger.Print("dbfailover: pinned master ", p.name(db), " is detected as ", r)
+}
+
+func (p *DBs) known(db *sql.DB) bool {
+	for _, d := range p.dbs {
+		if d == db {
+			return true
+		}
+	}
+	return false
+}
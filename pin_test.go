@@ -0,0 +1,52 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPinMaster(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	state := map[*sql.DB]dbStatus{
+		db1: {role: RoleMaster},
+		db2: {role: RoleSlave},
+	}
+	p := &DBs{
+		dbs:    []*sql.DB{db1, db2},
+		state:  state,
+		active: makeSelection(state, db1),
+		config: Config{Logger: nopLogger{}},
+	}
+
+	if err := p.PinMaster(&sql.DB{}, 0); err != ErrUnknownDatabase {
+		t.Fatalf("pinning unknown database, expected %v, got %v", ErrUnknownDatabase, err)
+	}
+
+	if err := p.PinMaster(db2, 0); err != nil {
+		t.Fatalf("pinning master: %v", err)
+	}
+	if m := p.Master(); m != db2 {
+		t.Errorf("pinned master is not returned")
+	}
+	if top := p.Topology(); top.Master != db1 || top.PinnedMaster != db2 {
+		t.Errorf("topology does not report detected and pinned masters, got %+v", top)
+	}
+
+	p.UnpinMaster()
+	if m := p.Master(); m != db1 {
+		t.Errorf("detected master is not returned after unpinning")
+	}
+
+	if err := p.PinMaster(db2, time.Millisecond); err != nil {
+		t.Fatalf("pinning master: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if m := p.Master(); m != db1 {
+		t.Errorf("detected master is not returned after pin expired")
+	}
+	if top := p.Topology(); top.PinnedMaster != nil {
+		t.Errorf("expired pin is reported in topology")
+	}
+}
@@ -0,0 +1,63 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Probe is a user defined health check run on every server together with
+// built-in status checks. Failing probe marks server offline, or degraded if
+// Degraded is set. Degraded servers are still used, but healthy slaves are
+// preferred over degraded ones.
+type Probe struct {
+	Name     string
+	Check    func(ctx context.Context, db Querier) error
+	Degraded bool
+}
+
+// QueryProbe creates a probe which fails if query fails or returns no rows,
+// example: QueryProbe("migrations", "SELECT 1 FROM schema_migrations WHERE
+// version = ?", 42).
+func QueryProbe(name string, query string, args ...interface{}) Probe {
+	return Probe{
+		Name: name,
+		Check: func(ctx context.Context, db Querier) error {
+			rows, err := db.QueryContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			if !rows.Next() {
+				if err := rows.Err(); err != nil {
+					return err
+				}
+				return sql.ErrNoRows
+			}
+			return rows.Err()
+		},
+	}
+}
+
+type probeStatus struct {
+	degraded bool
+	latency  time.Duration
+	err      *CheckError
+}
+
+func runProbe(db Querier, p Probe, timeout time.Duration) probeStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.Check(ctx, db)
+	ps := probeStatus{
+		degraded: p.Degraded,
+		latency:  time.Since(start),
+	}
+	if err != nil {
+		ps.err = newCheckError(CheckProbe, err)
+		ps.err.Probe = p.Name
+	}
+	return ps
+}
@@ -0,0 +1,187 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func init() {
+	sql.Register("dbfailover_probe_driver", probeDriver{})
+}
+
+// probeDriver opens connections answering every query according to DSN:
+// "rows" returns a single row, "empty" returns no rows, "block" waits until
+// query context is done, "mysql" rejects SHOW ALL SLAVES STATUS with a syntax
+// error and returns no rows otherwise, anything else is returned as a query
+// error.
+type probeDriver struct{}
+
+func (probeDriver) Open(dsn string) (driver.Conn, error) {
+	return probeConn(dsn), nil
+}
+
+type probeConn string
+
+func (c probeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch c {
+	case "rows":
+		return &probeRows{left: 1}, nil
+	case "empty":
+		return &probeRows{}, nil
+	case "block":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case "mysql":
+		if query == "SHOW ALL SLAVES STATUS" {
+			return nil, &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+		}
+		return &probeRows{}, nil
+	}
+	return nil, errors.New(string(c))
+}
+
+func (probeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (probeConn) Close() error {
+	return nil
+}
+
+func (probeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+type probeRows struct {
+	left int
+}
+
+func (r *probeRows) Columns() []string {
+	return []string{"1"}
+}
+
+func (r *probeRows) Close() error {
+	return nil
+}
+
+func (r *probeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	dest[0] = int64(1)
+	return nil
+}
+
+func openProbeDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("dbfailover_probe_driver", dsn)
+	if err != nil {
+		t.Fatalf("opening probe db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestQueryProbe(t *testing.T) {
+	tests := []struct {
+		msg  string
+		dsn  string
+		err  error
+		kind ErrorKind
+	}{
+		{
+			msg: "row returned",
+			dsn: "rows",
+		},
+		{
+			msg:  "no rows",
+			dsn:  "empty",
+			err:  sql.ErrNoRows,
+			kind: ErrorOther,
+		},
+		{
+			msg:  "query error",
+			dsn:  "boom",
+			kind: ErrorOther,
+		},
+		{
+			msg:  "timeout",
+			dsn:  "block",
+			err:  context.DeadlineExceeded,
+			kind: ErrorTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			p := QueryProbe("migrations", "SELECT 1 FROM schema_migrations WHERE version = ?", 42)
+			ps := runProbe(openProbeDB(t, test.dsn), p, 50*time.Millisecond)
+			if test.dsn == "rows" {
+				if ps.err != nil {
+					t.Fatalf("unexpected error: %v", ps.err)
+				}
+				return
+			}
+			if ps.err == nil {
+				t.Fatal("expected error")
+			}
+			if ps.err.Check != CheckProbe || ps.err.Probe != "migrations" {
+				t.Errorf("expected %v check of migrations probe, got %v of %q", CheckProbe, ps.err.Check, ps.err.Probe)
+			}
+			if ps.err.Kind != test.kind {
+				t.Errorf("kind, expected %v, got %v", test.kind, ps.err.Kind)
+			}
+			if test.err != nil && !errors.Is(ps.err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, ps.err)
+			}
+		})
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	errFailed := errors.New("replication lag table is empty")
+	p := Probe{
+		Name: "lag",
+		Check: func(ctx context.Context, db Querier) error {
+			return errFailed
+		},
+		Degraded: true,
+	}
+	ps := runProbe(nil, p, time.Second)
+	if !ps.degraded {
+		t.Error("degraded flag is not kept")
+	}
+	if ps.err == nil || !errors.Is(ps.err, errFailed) {
+		t.Fatalf("expected %v, got %v", errFailed, ps.err)
+	}
+	if want := "probe lag check: other: " + errFailed.Error(); ps.err.Error() != want {
+		t.Errorf("expected %q, got %q", want, ps.err.Error())
+	}
+
+	p.Check = func(ctx context.Context, db Querier) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ps = runProbe(nil, p, 10*time.Millisecond)
+	if ps.err == nil || ps.err.Kind != ErrorTimeout {
+		t.Errorf("expected timeout, got %v", ps.err)
+	}
+	if ps.latency < 10*time.Millisecond {
+		t.Errorf("latency %v is shorter than timeout", ps.latency)
+	}
+
+	p.Check = func(ctx context.Context, db Querier) error {
+		return nil
+	}
+	if ps = runProbe(nil, p, time.Second); ps.err != nil {
+		t.Errorf("unexpected error: %v", ps.err)
+	}
+}
@@ -0,0 +1,35 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// quorumLost returns masters not replicated from by a majority of replicas.
+// Every reachable non-master server with configured replication is a voter,
+// without voters every master is lost.
+func quorumLost(statuses map[*sql.DB]dbStatus) map[*sql.DB]bool {
+	voters := 0
+	for _, s := range statuses {
+		if s.role != RoleMaster && s.masterAddr != "" {
+			voters++
+		}
+	}
+
+	lost := make(map[*sql.DB]bool)
+	for db, m := range statuses {
+		if m.role != RoleMaster {
+			continue
+		}
+		votes := 0
+		for _, s := range statuses {
+			if s.role != RoleMaster && m.addr != "" && strings.EqualFold(s.masterAddr, m.addr) {
+				votes++
+			}
+		}
+		if 2*votes <= voters || voters == 0 {
+			lost[db] = true
+		}
+	}
+	return lost
+}
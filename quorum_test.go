@@ -0,0 +1,153 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestQuorumLost(t *testing.T) {
+	m1 := &sql.DB{}
+	m2 := &sql.DB{}
+	s1 := &sql.DB{}
+	s2 := &sql.DB{}
+	s3 := &sql.DB{}
+
+	master := func(addr string) dbStatus {
+		return dbStatus{role: RoleMaster, addr: addr}
+	}
+	replica := func(addr string) dbStatus {
+		return dbStatus{role: RoleSlave, masterAddr: addr}
+	}
+
+	tests := []struct {
+		name     string
+		statuses map[*sql.DB]dbStatus
+		lost     []*sql.DB
+	}{
+		{
+			name: "all replicas follow master",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master("m1:3306"),
+				s1: replica("m1:3306"),
+				s2: replica("M1:3306"),
+			},
+		},
+		{
+			name: "stray writable server",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master("m1:3306"),
+				m2: master("m2:3306"),
+				s1: replica("m1:3306"),
+				s2: replica("m1:3306"),
+				s3: replica("m2:3306"),
+			},
+			lost: []*sql.DB{m2},
+		},
+		{
+			name: "split vote",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master("m1:3306"),
+				m2: master("m2:3306"),
+				s1: replica("m1:3306"),
+				s2: replica("m2:3306"),
+			},
+			lost: []*sql.DB{m1, m2},
+		},
+		{
+			name: "offline replicas with replication configured vote",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master("m1:3306"),
+				s1: replica("m1:3306"),
+				s2: {role: RoleOffline, masterAddr: "old:3306"},
+				s3: {role: RoleOffline, masterAddr: "old:3306"},
+			},
+			lost: []*sql.DB{m1},
+		},
+		{
+			name: "unreachable replicas do not vote",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master("m1:3306"),
+				s1: replica("m1:3306"),
+				s2: {role: RoleOffline},
+			},
+		},
+		{
+			name: "no replicas",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master("m1:3306"),
+			},
+			lost: []*sql.DB{m1},
+		},
+		{
+			name: "unknown master address",
+			statuses: map[*sql.DB]dbStatus{
+				m1: master(""),
+				s1: replica("m1:3306"),
+			},
+			lost: []*sql.DB{m1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lost := quorumLost(test.statuses)
+			if len(lost) != len(test.lost) {
+				t.Fatalf("got %d masters without quorum, want %d", len(lost), len(test.lost))
+			}
+			for _, db := range test.lost {
+				if !lost[db] {
+					t.Errorf("master %p expected to lose quorum", db)
+				}
+			}
+		})
+	}
+}
+
+func TestQuorumSwitchover(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	db3 := &sql.DB{}
+	replica := func(addr string) dbStatus {
+		return dbStatus{role: RoleSlave, masterAddr: addr}
+	}
+	p := runDBs(t, []*sql.DB{db1, db2, db3}, map[*sql.DB]dbStatus{
+		db1: {role: RoleMaster, addr: "db1:3306"},
+		db2: replica("db1:3306"),
+		db3: replica("db1:3306"),
+	}, Config{MasterQuorum: true})
+
+	// switchover selects new master before replicas are pointed to it
+	p.overrides <- []statusUpdate{
+		{db: db2, status: dbStatus{role: RoleMaster}},
+		{db: db1, status: dbStatus{role: RoleOffline}},
+	}
+	p.updates <- nil
+	if m := p.Master(); m != db2 {
+		t.Fatal("promoted master is not selected")
+	}
+
+	update(p, map[*sql.DB]dbStatus{
+		db1: replica("db2:3306"),
+		db2: {role: RoleMaster, addr: "db2:3306"},
+	})
+	if m := p.Master(); m != db2 {
+		t.Fatal("promoted master is not selected while replicas are pointed to it")
+	}
+
+	update(p, map[*sql.DB]dbStatus{db3: replica("db2:3306")})
+	if m := p.Master(); m != db2 {
+		t.Fatal("confirmed master is not selected")
+	}
+
+	// once confirmed promoted master is subject to the quorum
+	update(p, map[*sql.DB]dbStatus{
+		db1: replica("db4:3306"),
+		db3: replica("db4:3306"),
+	})
+	p.mu.RLock()
+	master := p.active.master
+	p.mu.RUnlock()
+	if master != nil {
+		t.Error("master without quorum is selected")
+	}
+}
@@ -0,0 +1,139 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// ErrClusterExists is returned from Registry.Add if cluster with the same name
+// is already registered.
+var ErrClusterExists = errors.New("cluster already registered")
+
+// Stats holds status check counters.
+type Stats struct {
+	Checks       uint64 // completed server status checks
+	FailedChecks uint64 // checks detecting server as offline
+	Pending      int    // checks waiting to be scheduled
+}
+
+func (p *DBs) countCheck(status dbStatus) {
+	p.checks.Add(1)
+	if status.role == RoleOffline {
+		p.failedChecks.Add(1)
+	}
+}
+
+// Stats returns status check counters of DBs. For clusters of a Registry
+// Pending counts checks of all clusters.
+func (p *DBs) Stats() Stats {
+	return Stats{
+		Checks:       p.checks.Load(),
+		FailedChecks: p.failedChecks.Load(),
+		Pending:      p.sched.pending(),
+	}
+}
+
+// Registry manages many named DB clusters, for example one per shard. All
+// clusters share a single check scheduler, so no long running go-routines are
+// started per server or cluster apart from the status processing one.
+type Registry struct {
+	sched    *scheduler
+	ctx      context.Context
+	cancel   func()
+	clusters map[string]*DBs
+	mu       sync.RWMutex
+}
+
+// NewRegistry creates an empty registry. At most maxConcurrentChecks status
+// checks are run at the same time across all clusters, zero means no limit.
+func NewRegistry(maxConcurrentChecks int) *Registry {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Registry{
+		sched:    newScheduler(maxConcurrentChecks),
+		ctx:      ctx,
+		cancel:   cancel,
+		clusters: make(map[string]*DBs),
+	}
+	go r.sched.loop(ctx)
+	return r
+}
+
+// Add starts monitoring a new cluster. Like NewWithConfig it blocks until
+// initial cluster state is detected.
+func (r *Registry) Add(name string, dbs []*sql.DB, cfg Config) error {
+	r.mu.RLock()
+	_, ok := r.clusters[name]
+	r.mu.RUnlock()
+	if ok {
+		return ErrClusterExists
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	p, err := newDBs(ctx, cancel, dbs, cfg, r.sched)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clusters[name]; ok {
+		p.Stop()
+		return ErrClusterExists
+	}
+	r.clusters[name] = p
+	return nil
+}
+
+// Cluster returns a cluster registered with the given name or nil if it is not
+// found.
+func (r *Registry) Cluster(name string) *DBs {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clusters[name]
+}
+
+// Remove stops monitoring a cluster and removes it from the registry.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	p, ok := r.clusters[name]
+	delete(r.clusters, name)
+	r.mu.Unlock()
+
+	if ok {
+		p.Stop()
+	}
+}
+
+// Status returns topology of all registered clusters.
+func (r *Registry) Status() map[string]Topology {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]Topology, len(r.clusters))
+	for name, p := range r.clusters {
+		out[name] = p.Topology()
+	}
+	return out
+}
+
+// Stats returns status check counters summed across all clusters.
+func (r *Registry) Stats() Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	st := Stats{Pending: r.sched.pending()}
+	for _, p := range r.clusters {
+		st.Checks += p.checks.Load()
+		st.FailedChecks += p.failedChecks.Load()
+	}
+	return st
+}
+
+// Stop stops monitoring of all clusters. Clusters can be still used after Stop
+// is called, they return last seen state.
+func (r *Registry) Stop() {
+	r.cancel()
+}
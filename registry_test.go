@@ -0,0 +1,55 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(2)
+	defer r.Stop()
+
+	adb := startOfflineInstance(t)
+	bdb := startOfflineInstance(t)
+	cfg := Config{
+		CheckInterval: 10 * time.Millisecond,
+		CheckTimeout:  100 * time.Millisecond,
+	}
+
+	if err := r.Add("a", []*sql.DB{adb}, cfg); err != nil {
+		t.Fatalf("adding cluster a: %v", err)
+	}
+	if err := r.Add("b", []*sql.DB{bdb}, cfg); err != nil {
+		t.Fatalf("adding cluster b: %v", err)
+	}
+	if err := r.Add("a", []*sql.DB{bdb}, cfg); err != ErrClusterExists {
+		t.Errorf("adding duplicate cluster, expected %v, got %v", ErrClusterExists, err)
+	}
+
+	if m := r.Cluster("a").Master(); m != adb {
+		t.Errorf("cluster a master does not match")
+	}
+	if m := r.Cluster("b").Master(); m != bdb {
+		t.Errorf("cluster b master does not match")
+	}
+	if r.Cluster("c") != nil {
+		t.Errorf("unknown cluster is not nil")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	st := r.Stats()
+	if st.Checks == 0 || st.FailedChecks != st.Checks {
+		t.Errorf("expected failed checks to be counted, got %+v", st)
+	}
+
+	status := r.Status()
+	if len(status) != 2 || status["a"].Nodes[0].Role != RoleOffline {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	r.Remove("a")
+	if r.Cluster("a") != nil {
+		t.Errorf("removed cluster is still registered")
+	}
+}
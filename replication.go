@@ -0,0 +1,124 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"time"
+)
+
+// replicationSource returns monitored server db replicates from. It returns nil
+// if db is not a replica or its source is unknown or not monitored.
+func replicationSource(statuses map[*sql.DB]dbStatus, db *sql.DB) *sql.DB {
+	s := statuses[db]
+	if s.masterServerID == 0 && s.masterUUID == "" {
+		return nil
+	}
+	for src, ss := range statuses {
+		if src == db {
+			continue
+		}
+		if s.masterUUID != "" && ss.serverUUID != "" {
+			if s.masterUUID == ss.serverUUID {
+				return src
+			}
+			continue
+		}
+		if s.masterServerID != 0 && s.masterServerID == ss.serverID {
+			return src
+		}
+	}
+	return nil
+}
+
+// follows reports if db replicates from master directly or via a chain of
+// monitored servers.
+func follows(statuses map[*sql.DB]dbStatus, db, master *sql.DB) bool {
+	for i := 0; i < len(statuses); i++ {
+		db = replicationSource(statuses, db)
+		switch db {
+		case nil:
+			return false
+		case master:
+			return true
+		}
+	}
+	// replication cycle
+	return false
+}
+
+// orphanedSlaves returns slaves not replicating from master. Slaves with
+// unknown replication source and all slaves when master identity is unknown
+// are not reported.
+func orphanedSlaves(statuses map[*sql.DB]dbStatus, master *sql.DB) map[*sql.DB]bool {
+	m := statuses[master]
+	if master == nil || (m.serverID == 0 && m.serverUUID == "") {
+		return nil
+	}
+
+	orphans := make(map[*sql.DB]bool)
+	for db, s := range statuses {
+		if (s.role != RoleSlave && s.role != RoleDelayed) || (s.masterServerID == 0 && s.masterUUID == "") {
+			continue
+		}
+		if !follows(statuses, db, master) {
+			orphans[db] = true
+		}
+	}
+	return orphans
+}
+
+// chain describes replication path from master to a server.
+type chain struct {
+	depth int           // 1 for direct replicas
+	delay time.Duration // cumulative replication delay without configured MASTER_DELAY
+}
+
+// replicationChains returns replication paths of servers replicating from
+// master directly or via other monitored servers (intermediate masters).
+func replicationChains(statuses map[*sql.DB]dbStatus, master *sql.DB) map[*sql.DB]chain {
+	if master == nil {
+		return nil
+	}
+
+	chains := make(map[*sql.DB]chain)
+	for db := range statuses {
+		if db == master {
+			continue
+		}
+		var c chain
+		for src := db; src != nil && c.depth < len(statuses); {
+			c.depth++
+			c.delay += max(statuses[src].delay-statuses[src].sqlDelay, 0)
+			if src = replicationSource(statuses, src); src == master {
+				chains[db] = c
+				break
+			}
+		}
+	}
+	return chains
+}
+
+// laggingSlaves returns servers with cumulative replication delay above max.
+func laggingSlaves(chains map[*sql.DB]chain, max time.Duration) map[*sql.DB]bool {
+	lagging := make(map[*sql.DB]bool)
+	for db, c := range chains {
+		if c.delay > max {
+			lagging[db] = true
+		}
+	}
+	return lagging
+}
+
+// withDepth returns a copy of statuses with replication depth set for slave
+// selection. Slaves with unknown path to master are ranked last.
+func withDepth(statuses map[*sql.DB]dbStatus, chains map[*sql.DB]chain) map[*sql.DB]dbStatus {
+	out := make(map[*sql.DB]dbStatus, len(statuses))
+	for db, status := range statuses {
+		if c, ok := chains[db]; ok {
+			status.depth = c.depth
+		} else {
+			status.depth = len(statuses) + 1
+		}
+		out[db] = status
+	}
+	return out
+}
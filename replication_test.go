@@ -0,0 +1,168 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestOrphanedSlaves(t *testing.T) {
+	m := &sql.DB{}
+	s1 := &sql.DB{}
+	s2 := &sql.DB{}
+	s3 := &sql.DB{}
+
+	tests := []struct {
+		name     string
+		statuses map[*sql.DB]dbStatus
+		master   *sql.DB
+		orphans  []*sql.DB
+	}{
+		{
+			name: "direct replicas",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster, serverID: 1},
+				s1: {role: RoleSlave, serverID: 2, masterServerID: 1},
+				s2: {role: RoleSlave, serverID: 3, masterServerID: 1},
+			},
+			master: m,
+		},
+		{
+			name: "replication chain",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster, serverID: 1},
+				s1: {role: RoleSlave, serverID: 2, masterServerID: 1},
+				s2: {role: RoleSlave, serverID: 3, masterServerID: 2},
+			},
+			master: m,
+		},
+		{
+			name: "decommissioned master",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster, serverID: 1},
+				s1: {role: RoleSlave, serverID: 2, masterServerID: 1},
+				s2: {role: RoleSlave, serverID: 3, masterServerID: 9},
+				s3: {role: RoleSlave, serverID: 4, masterServerID: 3},
+			},
+			master:  m,
+			orphans: []*sql.DB{s2, s3},
+		},
+		{
+			name: "uuid takes precedence",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster, serverID: 1, serverUUID: "a"},
+				s1: {role: RoleSlave, serverID: 2, masterServerID: 1, masterUUID: "a"},
+				s2: {role: RoleSlave, serverID: 3, masterServerID: 1, masterUUID: "b"},
+			},
+			master:  m,
+			orphans: []*sql.DB{s2},
+		},
+		{
+			name: "replication cycle",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster, serverID: 1},
+				s1: {role: RoleSlave, serverID: 2, masterServerID: 3},
+				s2: {role: RoleSlave, serverID: 3, masterServerID: 2},
+			},
+			master:  m,
+			orphans: []*sql.DB{s1, s2},
+		},
+		{
+			name: "unknown replication source",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster, serverID: 1},
+				s1: {role: RoleSlave, serverID: 2},
+			},
+			master: m,
+		},
+		{
+			name: "unknown master identity",
+			statuses: map[*sql.DB]dbStatus{
+				m:  {role: RoleMaster},
+				s1: {role: RoleSlave, serverID: 2, masterServerID: 9},
+			},
+			master: m,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			orphans := orphanedSlaves(test.statuses, test.master)
+			if len(orphans) != len(test.orphans) {
+				t.Fatalf("got %d orphaned slaves, want %d", len(orphans), len(test.orphans))
+			}
+			for _, db := range test.orphans {
+				if !orphans[db] {
+					t.Errorf("slave %p expected to be orphaned", db)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicationChains(t *testing.T) {
+	m := &sql.DB{}
+	direct := &sql.DB{}
+	intermediate := &sql.DB{}
+	cascaded := &sql.DB{}
+	other := &sql.DB{}
+	delayed := &sql.DB{}
+	belowDelayed := &sql.DB{}
+
+	statuses := map[*sql.DB]dbStatus{
+		m:            {role: RoleMaster, serverID: 1},
+		direct:       {role: RoleSlave, serverID: 2, masterServerID: 1, delay: time.Second},
+		intermediate: {role: RoleSlave, serverID: 3, masterServerID: 1, delay: 2 * time.Minute},
+		cascaded:     {role: RoleSlave, serverID: 4, masterServerID: 3, delay: 4 * time.Minute},
+		other:        {role: RoleSlave, serverID: 5, masterServerID: 9},
+		delayed:      {role: RoleDelayed, serverID: 6, masterServerID: 1, delay: time.Hour + time.Minute, sqlDelay: time.Hour},
+		belowDelayed: {role: RoleSlave, serverID: 7, masterServerID: 6, delay: time.Second},
+	}
+
+	chains := replicationChains(statuses, m)
+	want := map[*sql.DB]chain{
+		direct:       {depth: 1, delay: time.Second},
+		intermediate: {depth: 1, delay: 2 * time.Minute},
+		cascaded:     {depth: 2, delay: 6 * time.Minute},
+		delayed:      {depth: 1, delay: time.Minute},
+		belowDelayed: {depth: 2, delay: time.Minute + time.Second},
+	}
+	if len(chains) != len(want) {
+		t.Fatalf("got %d chains, want %d", len(chains), len(want))
+	}
+	for db, c := range want {
+		if chains[db] != c {
+			t.Errorf("got chain %+v, want %+v", chains[db], c)
+		}
+	}
+
+	lagging := laggingSlaves(chains, 5*time.Minute)
+	if len(lagging) != 1 || !lagging[cascaded] {
+		t.Errorf("got lagging slaves %v, want cascaded slave only", lagging)
+	}
+
+	ranked := withDepth(statuses, chains)
+	if ranked[direct].depth != 1 || ranked[cascaded].depth != 2 || ranked[other].depth <= 2 {
+		t.Errorf("unexpected slave ranking %v", ranked)
+	}
+}
+
+func TestDelayedSlaveNotLagging(t *testing.T) {
+	m := &sql.DB{}
+	slave := &sql.DB{}
+	delayed := &sql.DB{}
+	statuses := map[*sql.DB]dbStatus{
+		m:       {role: RoleMaster, serverID: 1},
+		slave:   {role: RoleSlave, serverID: 2, masterServerID: 1, delay: time.Second},
+		delayed: {role: RoleDelayed, serverID: 3, masterServerID: 1, delay: 2 * time.Hour, sqlDelay: 2 * time.Hour},
+	}
+	p := runDBs(t, []*sql.DB{m, slave, delayed}, statuses, Config{MaxTopologyAge: time.Minute})
+
+	update(p, statuses)
+	if db, err := p.DelayedE(); db != delayed || err != nil {
+		t.Errorf("expected delayed slave, got %v, %v", db, err)
+	}
+	if s := p.Slave(); s != slave {
+		t.Errorf("expected regular slave, got %v", s)
+	}
+}
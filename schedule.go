@@ -0,0 +1,78 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"math/rand/v2"
+	"time"
+)
+
+// schedule computes intervals between checks of a single server.
+type schedule struct {
+	interval       time.Duration
+	maxInterval    time.Duration
+	changeInterval time.Duration
+	changeChecks   int
+	jitter         time.Duration
+
+	checked  bool
+	role     Role
+	offline  int // consecutive offline checks
+	fastLeft int // checks left to do with changeInterval
+}
+
+func newSchedule(db *sql.DB, cfg Config) *schedule {
+	s := &schedule{
+		interval:       cfg.CheckInterval,
+		maxInterval:    cfg.OfflineMaxInterval,
+		changeInterval: cfg.ChangeCheckInterval,
+		changeChecks:   cfg.ChangeChecks,
+		jitter:         cfg.CheckJitter,
+	}
+	if d, ok := cfg.CheckIntervals[db]; ok && d > 0 {
+		s.interval = d
+	}
+	return s
+}
+
+// next returns duration to wait before the next check given the result of the
+// last check, status is nil before the first check.
+func (s *schedule) next(status *dbStatus) time.Duration {
+	d := s.interval
+	if status != nil {
+		d = s.observe(*status)
+	}
+	if s.jitter > 0 {
+		d += rand.N(s.jitter)
+	}
+	return d
+}
+
+func (s *schedule) observe(status dbStatus) time.Duration {
+	if s.checked && status.role != s.role && s.changeInterval > 0 {
+		s.fastLeft = s.changeChecks
+	}
+	s.checked = true
+	s.role = status.role
+
+	if status.role == RoleOffline {
+		s.offline++
+	} else {
+		s.offline = 0
+	}
+
+	if s.fastLeft > 0 {
+		s.fastLeft--
+		return s.changeInterval
+	}
+
+	d := s.interval
+	if s.maxInterval > s.interval {
+		for i := 1; i < s.offline && d < s.maxInterval; i++ {
+			d *= 2
+		}
+		if d > s.maxInterval {
+			d = s.maxInterval
+		}
+	}
+	return d
+}
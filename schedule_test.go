@@ -0,0 +1,61 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	db := &sql.DB{}
+	cfg := Config{
+		CheckInterval:       time.Second,
+		OfflineMaxInterval:  5 * time.Second,
+		ChangeCheckInterval: 100 * time.Millisecond,
+		ChangeChecks:        2,
+	}
+	s := newSchedule(db, cfg)
+
+	steps := []struct {
+		role Role
+		want time.Duration
+	}{
+		{role: RoleMaster, want: time.Second},
+		{role: RoleMaster, want: time.Second},
+		{role: RoleOffline, want: 100 * time.Millisecond},
+		{role: RoleOffline, want: 100 * time.Millisecond},
+		{role: RoleOffline, want: 4 * time.Second},
+		{role: RoleOffline, want: 5 * time.Second},
+		{role: RoleOffline, want: 5 * time.Second},
+		{role: RoleSlave, want: 100 * time.Millisecond},
+		{role: RoleSlave, want: 100 * time.Millisecond},
+		{role: RoleSlave, want: time.Second},
+	}
+
+	if got := s.next(nil); got != time.Second {
+		t.Errorf("initial interval, expected %v, got %v", time.Second, got)
+	}
+	for i, step := range steps {
+		got := s.next(&dbStatus{role: step.role})
+		if got != step.want {
+			t.Errorf("step %d, expected %v, got %v", i, step.want, got)
+		}
+	}
+}
+
+func TestScheduleOverrideAndJitter(t *testing.T) {
+	db := &sql.DB{}
+	cfg := Config{
+		CheckInterval:  time.Second,
+		CheckIntervals: map[*sql.DB]time.Duration{db: 200 * time.Millisecond},
+		CheckJitter:    50 * time.Millisecond,
+	}
+	s := newSchedule(db, cfg)
+
+	for i := 0; i < 100; i++ {
+		got := s.next(&dbStatus{role: RoleOffline})
+		if got < 200*time.Millisecond || got >= 250*time.Millisecond {
+			t.Fatalf("expected interval in [200ms, 250ms), got %v", got)
+		}
+	}
+}
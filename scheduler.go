@@ -0,0 +1,198 @@
+package dbfailover
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// job is a periodic task run by scheduler.
+type job interface {
+	// run executes the task, it returns delay before the next run or
+	// false if job should not be rescheduled.
+	run(ctx context.Context) (time.Duration, bool)
+	// close releases job resources after it is not scheduled anymore.
+	close()
+}
+
+type entry struct {
+	at  time.Time
+	ctx context.Context
+	job job
+}
+
+type entries []*entry
+
+func (q entries) Len() int            { return len(q) }
+func (q entries) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q entries) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *entries) Push(x interface{}) { *q = append(*q, x.(*entry)) }
+func (q *entries) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
+
+// scheduler runs jobs of one or many DBs from a single go-routine, every job
+// run is executed in a separate short lived go-routine. Job is rescheduled
+// only after its run is finished, so a single job is never run concurrently.
+type scheduler struct {
+	mu    sync.Mutex
+	queue entries
+	wake  chan struct{}
+	sem   chan struct{} // limits concurrent job runs, nil if unlimited
+}
+
+func newScheduler(maxConcurrent int) *scheduler {
+	s := &scheduler{
+		wake: make(chan struct{}, 1),
+	}
+	if maxConcurrent > 0 {
+		s.sem = make(chan struct{}, maxConcurrent)
+	}
+	return s
+}
+
+// add schedules job to be run after delay. Job is dropped once ctx is done.
+func (s *scheduler) add(ctx context.Context, j job, delay time.Duration) {
+	s.mu.Lock()
+	heap.Push(&s.queue, &entry{at: time.Now().Add(delay), ctx: ctx, job: j})
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pending returns number of queued jobs.
+func (s *scheduler) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func (s *scheduler) loop(ctx context.Context) {
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+
+	for {
+		s.mu.Lock()
+		wait := time.Hour
+		if len(s.queue) > 0 {
+			wait = time.Until(s.queue[0].at)
+		}
+		if wait <= 0 {
+			e := heap.Pop(&s.queue).(*entry)
+			s.mu.Unlock()
+			s.dispatch(ctx, e)
+			continue
+		}
+		s.mu.Unlock()
+
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		t.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			queue := s.queue
+			s.queue = nil
+			s.mu.Unlock()
+			for _, e := range queue {
+				e.job.close()
+			}
+			return
+		case <-s.wake:
+		case <-t.C:
+		}
+	}
+}
+
+func (s *scheduler) dispatch(ctx context.Context, e *entry) {
+	if e.ctx.Err() != nil {
+		e.job.close()
+		return
+	}
+	if s.sem != nil {
+		select {
+		case <-ctx.Done():
+			e.job.close()
+			return
+		case s.sem <- struct{}{}:
+		}
+	}
+
+	go func() {
+		next, ok := e.job.run(e.ctx)
+		if s.sem != nil {
+			<-s.sem
+		}
+		if !ok || e.ctx.Err() != nil {
+			e.job.close()
+			return
+		}
+		s.add(e.ctx, e.job, next)
+	}()
+}
+
+// nodeJob checks a single server and sends its status to DBs.run.
+type nodeJob struct {
+	p       *DBs
+	checker *checker
+	sched   *schedule
+	updates chan<- []statusUpdate
+}
+
+func (j *nodeJob) run(ctx context.Context) (time.Duration, bool) {
+	started := time.Now()
+	status := j.checker.check(j.p.config)
+	j.p.countCheck(status)
+	select {
+	case <-ctx.Done():
+		return 0, false
+	case j.updates <- []statusUpdate{{db: j.checker.db, status: status, started: started}}:
+	}
+	return j.sched.next(&status), true
+}
+
+func (j *nodeJob) close() {
+	j.checker.close()
+}
+
+// roundJob checks all servers of DBs together and sends statuses as a single
+// batch to DBs.run.
+type roundJob struct {
+	p       *DBs
+	updates chan<- []statusUpdate
+}
+
+func (j *roundJob) run(ctx context.Context) (time.Duration, bool) {
+	started := time.Now()
+	state := checkBatch(j.p.checkers, j.p.config)
+	us := make([]statusUpdate, 0, len(j.p.checkers))
+	for _, c := range j.p.checkers {
+		j.p.countCheck(state[c.db])
+		us = append(us, statusUpdate{db: c.db, status: state[c.db], started: started})
+	}
+	select {
+	case <-ctx.Done():
+		return 0, false
+	case j.updates <- us:
+	}
+	return j.p.config.CheckInterval, true
+}
+
+func (j *roundJob) close() {
+	for _, c := range j.p.checkers {
+		c.close()
+	}
+}
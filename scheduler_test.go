@@ -0,0 +1,81 @@
+package dbfailover
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingJob struct {
+	runs    atomic.Int32
+	limit   int32
+	running atomic.Int32
+	overlap atomic.Bool
+	closed  chan struct{}
+}
+
+func (j *countingJob) run(ctx context.Context) (time.Duration, bool) {
+	if j.running.Add(1) > 1 {
+		j.overlap.Store(true)
+	}
+	defer j.running.Add(-1)
+	time.Sleep(time.Millisecond)
+	return time.Millisecond, j.runs.Add(1) < j.limit
+}
+
+func (j *countingJob) close() {
+	close(j.closed)
+}
+
+func TestScheduler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := newScheduler(1)
+	go s.loop(ctx)
+
+	jobs := []*countingJob{
+		{limit: 5, closed: make(chan struct{})},
+		{limit: 3, closed: make(chan struct{})},
+	}
+	for _, j := range jobs {
+		s.add(ctx, j, 0)
+	}
+
+	for i, j := range jobs {
+		select {
+		case <-j.closed:
+		case <-time.After(time.Second):
+			t.Fatalf("job %d was not finished", i)
+		}
+		if runs := j.runs.Load(); runs != j.limit {
+			t.Errorf("job %d, expected %d runs, got %d", i, j.limit, runs)
+		}
+		if j.overlap.Load() {
+			t.Errorf("job %d was run concurrently", i)
+		}
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := newScheduler(0)
+	done := make(chan struct{})
+	go func() {
+		s.loop(ctx)
+		close(done)
+	}()
+
+	j := &countingJob{limit: 100, closed: make(chan struct{})}
+	s.add(ctx, j, time.Hour)
+	cancel()
+
+	select {
+	case <-j.closed:
+	case <-time.After(time.Second):
+		t.Fatalf("queued job was not closed")
+	}
+	<-done
+}
@@ -8,6 +8,7 @@ import (
 type selection struct {
 	master          *sql.DB
 	slave           *sql.DB
+	delayed         *sql.DB
 	lastMaster      *sql.DB
 	multipleMasters bool
 }
@@ -17,27 +18,33 @@ func makeSelection(statuses map[*sql.DB]dbStatus, lastMaster *sql.DB) selection
 		master          *sql.DB
 		masterLatency   time.Duration
 		slave           *sql.DB
-		slaveLatency    time.Duration
+		slaveStatus     dbStatus
+		delayed         *sql.DB
+		delayedStatus   dbStatus
 		multipleMasters bool
 	)
 
 	for db, status := range statuses {
 		switch status.role {
-		case roleOffline:
+		case RoleOffline:
 			continue
-		case roleMaster:
+		case RoleMaster:
 			multipleMasters = multipleMasters || master != nil
 
 			if masterLatency == 0 || status.latency < masterLatency {
 				master = db
 				masterLatency = status.latency
 			}
-		case roleSlave:
-			if slaveLatency == 0 || status.latency < slaveLatency {
+		case RoleSlave:
+			if slave == nil || betterSlave(status, slaveStatus) {
 				slave = db
-				slaveLatency = status.latency
+				slaveStatus = status
+			}
+		case RoleDelayed:
+			if delayed == nil || betterSlave(status, delayedStatus) {
+				delayed = db
+				delayedStatus = status
 			}
-
 		}
 	}
 	if slave == nil {
@@ -50,7 +57,79 @@ func makeSelection(statuses map[*sql.DB]dbStatus, lastMaster *sql.DB) selection
 	return selection{
 		master:          master,
 		slave:           slave,
+		delayed:         delayed,
 		lastMaster:      lastMaster,
 		multipleMasters: multipleMasters,
 	}
 }
+
+// betterSlave reports if slave a is preferred over b. Healthy slaves are
+// preferred over degraded ones, then semi-sync slaves over async ones, then
+// slaves closer to master and then slaves with lower latency.
+func betterSlave(a, b dbStatus) bool {
+	if a.degraded != b.degraded {
+		return !a.degraded
+	}
+	if a.async != b.async {
+		return !a.async
+	}
+	if a.depth != b.depth {
+		return a.depth < b.depth
+	}
+	return a.latency < b.latency
+}
+
+// withOffline returns statuses with servers from any of offline sets treated
+// as offline. The original map is returned if there are no such servers,
+// otherwise it is copied.
+func withOffline(statuses map[*sql.DB]dbStatus, offline ...map[*sql.DB]bool) map[*sql.DB]dbStatus {
+	n := 0
+	for _, set := range offline {
+		n += len(set)
+	}
+	if n == 0 {
+		return statuses
+	}
+
+	out := make(map[*sql.DB]dbStatus, len(statuses))
+	for db, status := range statuses {
+		for _, set := range offline {
+			if set[db] {
+				status.role = RoleOffline
+			}
+		}
+		out[db] = status
+	}
+	return out
+}
+
+// settler holds back selection changes until the same selection is computed
+// for the whole window duration.
+type settler struct {
+	window  time.Duration
+	pending selection
+	since   time.Time
+}
+
+// settle returns selection that should be published given currently published
+// and newly computed selections.
+func (s *settler) settle(current, computed selection, now time.Time) selection {
+	if s.window <= 0 || computed == current {
+		s.reset()
+		return computed
+	}
+	if computed != s.pending || s.since.IsZero() {
+		s.pending = computed
+		s.since = now
+	}
+	if now.Sub(s.since) < s.window {
+		return current
+	}
+	s.reset()
+	return computed
+}
+
+func (s *settler) reset() {
+	s.pending = selection{}
+	s.since = time.Time{}
+}
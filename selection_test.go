@@ -23,7 +23,7 @@ func TestMakeSelection(t *testing.T) {
 		{
 			msg: "single master",
 			states: map[*sql.DB]dbStatus{
-				db1: {role: roleMaster},
+				db1: {role: RoleMaster},
 			},
 			want: selection{
 				master:     db1,
@@ -44,8 +44,8 @@ func TestMakeSelection(t *testing.T) {
 		{
 			msg: "one_master_one_slave",
 			states: map[*sql.DB]dbStatus{
-				db1: {role: roleMaster},
-				db2: {role: roleSlave},
+				db1: {role: RoleMaster},
+				db2: {role: RoleSlave},
 			},
 			want: selection{
 				master:     db1,
@@ -56,9 +56,9 @@ func TestMakeSelection(t *testing.T) {
 		{
 			msg: "one master two slaves pick lowest latency",
 			states: map[*sql.DB]dbStatus{
-				db1: {role: roleMaster, latency: 1 * time.Second},
-				db2: {role: roleSlave, latency: 5 * time.Second},
-				db3: {role: roleSlave, latency: 2 * time.Second},
+				db1: {role: RoleMaster, latency: 1 * time.Second},
+				db2: {role: RoleSlave, latency: 5 * time.Second},
+				db3: {role: RoleSlave, latency: 2 * time.Second},
 			},
 			want: selection{
 				master:     db1,
@@ -69,9 +69,9 @@ func TestMakeSelection(t *testing.T) {
 		{
 			msg: "two masters one slave pick lowest latency and set multiple master flag",
 			states: map[*sql.DB]dbStatus{
-				db1: {role: roleMaster, latency: 5 * time.Second},
-				db2: {role: roleMaster, latency: 2 * time.Second},
-				db3: {role: roleSlave, latency: 1 * time.Second},
+				db1: {role: RoleMaster, latency: 5 * time.Second},
+				db2: {role: RoleMaster, latency: 2 * time.Second},
+				db3: {role: RoleSlave, latency: 1 * time.Second},
 			},
 			want: selection{
 				master:          db2,
@@ -80,10 +80,23 @@ func TestMakeSelection(t *testing.T) {
 				multipleMasters: true,
 			},
 		},
+		{
+			msg: "prefer healthy slave over degraded one",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster, latency: 1 * time.Second},
+				db2: {role: RoleSlave, latency: 1 * time.Second, degraded: true},
+				db3: {role: RoleSlave, latency: 3 * time.Second},
+			},
+			want: selection{
+				master:     db1,
+				slave:      db3,
+				lastMaster: db1,
+			},
+		},
 		{
 			msg: "slave only",
 			states: map[*sql.DB]dbStatus{
-				db1: {role: roleSlave},
+				db1: {role: RoleSlave},
 			},
 			lastMaster: db2,
 			want: selection{
@@ -92,12 +105,38 @@ func TestMakeSelection(t *testing.T) {
 				lastMaster: db2,
 			},
 		},
+		{
+			msg: "prefer closer slave",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleSlave, depth: 2, latency: time.Millisecond},
+				db3: {role: RoleSlave, depth: 1, latency: time.Second},
+			},
+			want: selection{
+				master:     db1,
+				slave:      db3,
+				lastMaster: db1,
+			},
+		},
+		{
+			msg: "delayed slave",
+			states: map[*sql.DB]dbStatus{
+				db1: {role: RoleMaster},
+				db2: {role: RoleDelayed},
+			},
+			want: selection{
+				master:     db1,
+				slave:      db1,
+				delayed:    db2,
+				lastMaster: db1,
+			},
+		},
 		{
 			msg: "offline only",
 			states: map[*sql.DB]dbStatus{
-				db1: {role: roleOffline},
-				db2: {role: roleOffline},
-				db3: {role: roleOffline},
+				db1: {role: RoleOffline},
+				db2: {role: RoleOffline},
+				db3: {role: RoleOffline},
 			},
 			want: selection{
 				master:     nil,
@@ -116,3 +155,40 @@ func TestMakeSelection(t *testing.T) {
 		})
 	}
 }
+
+func TestSettler(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	start := time.Now()
+
+	a := selection{master: db1, slave: db2, lastMaster: db1}
+	b := selection{master: nil, slave: db2, lastMaster: db1}
+	c := selection{master: db2, slave: db2, lastMaster: db2}
+
+	s := settler{window: time.Second}
+	steps := []struct {
+		msg      string
+		computed selection
+		at       time.Duration
+		want     selection
+	}{
+		{msg: "unchanged", computed: a, at: 0, want: a},
+		{msg: "intermediate state held back", computed: b, at: 100 * time.Millisecond, want: a},
+		{msg: "new state held back", computed: c, at: 200 * time.Millisecond, want: a},
+		{msg: "new state not settled yet", computed: c, at: 1100 * time.Millisecond, want: a},
+		{msg: "new state settled", computed: c, at: 1200 * time.Millisecond, want: c},
+	}
+
+	current := a
+	for _, step := range steps {
+		current = s.settle(current, step.computed, start.Add(step.at))
+		if current != step.want {
+			t.Errorf("%s: expected %v, got %v", step.msg, step.want, current)
+		}
+	}
+
+	s = settler{}
+	if got := s.settle(a, c, start); got != c {
+		t.Errorf("without window expected %v, got %v", c, got)
+	}
+}
@@ -0,0 +1,76 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type semiSyncStatus struct {
+	masterEnabled bool // rpl_semi_sync_master_enabled
+	masterActive  bool // Rpl_semi_sync_master_status, off after async fallback
+	masterClients int  // Rpl_semi_sync_master_clients
+	slaveActive   bool // Rpl_semi_sync_slave_status
+	err           *CheckError
+}
+
+// fallback reports if semi-sync master has fallen back to asynchronous
+// replication.
+func (s semiSyncStatus) fallback() bool {
+	return s.masterEnabled && !s.masterActive
+}
+
+// checkSemiSyncStatus reads semi-synchronous replication plugin variables and
+// status. Servers without the plugin are reported as not using semi-sync.
+// MySQL source/replica names are supported.
+func checkSemiSyncStatus(db Querier, timeout time.Duration) semiSyncStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var ss semiSyncStatus
+	for _, q := range []string{
+		"SHOW GLOBAL VARIABLES LIKE 'rpl_semi_sync_%'",
+		"SHOW GLOBAL STATUS LIKE 'rpl_semi_sync_%'",
+	} {
+		rows, err := db.QueryContext(ctx, q)
+		if err != nil {
+			return semiSyncStatus{err: newCheckError(CheckSemiSync, err)}
+		}
+		all, err := scanRows(rows)
+		_ = rows.Close()
+		if err != nil {
+			return semiSyncStatus{err: newCheckError(CheckSemiSync, err)}
+		}
+		for _, vals := range all {
+			ss.set(vals["Variable_name"], vals["Value"])
+		}
+	}
+	return ss
+}
+
+func (s *semiSyncStatus) set(key, val string) {
+	r := strings.NewReplacer("source", "master", "replica", "slave")
+	switch r.Replace(strings.ToLower(key)) {
+	case "rpl_semi_sync_master_enabled":
+		s.masterEnabled = val == "ON"
+	case "rpl_semi_sync_master_status":
+		s.masterActive = val == "ON"
+	case "rpl_semi_sync_master_clients":
+		s.masterClients, _ = strconv.Atoi(val)
+	case "rpl_semi_sync_slave_status":
+		s.slaveActive = val == "ON"
+	}
+}
+
+// withSemiSync returns a copy of statuses with slaves not acknowledging
+// semi-sync replication marked as async for slave selection.
+func withSemiSync(statuses map[*sql.DB]dbStatus) map[*sql.DB]dbStatus {
+	out := make(map[*sql.DB]dbStatus, len(statuses))
+	for db, status := range statuses {
+		status.async = !status.semiSync.slaveActive
+		out[db] = status
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSemiSyncStatus(t *testing.T) {
+	tests := []struct {
+		msg      string
+		vars     map[string]string
+		want     semiSyncStatus
+		fallback bool
+	}{
+		{
+			msg: "plugin not installed",
+		},
+		{
+			msg: "acknowledging slave",
+			vars: map[string]string{
+				"rpl_semi_sync_slave_enabled": "ON",
+				"Rpl_semi_sync_slave_status":  "ON",
+			},
+			want: semiSyncStatus{slaveActive: true},
+		},
+		{
+			msg: "master",
+			vars: map[string]string{
+				"rpl_semi_sync_master_enabled": "ON",
+				"Rpl_semi_sync_master_status":  "ON",
+				"Rpl_semi_sync_master_clients": "2",
+			},
+			want: semiSyncStatus{masterEnabled: true, masterActive: true, masterClients: 2},
+		},
+		{
+			msg: "mysql source fallen back to async",
+			vars: map[string]string{
+				"rpl_semi_sync_source_enabled": "ON",
+				"Rpl_semi_sync_source_status":  "OFF",
+				"Rpl_semi_sync_source_clients": "0",
+			},
+			want:     semiSyncStatus{masterEnabled: true},
+			fallback: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			var got semiSyncStatus
+			for k, v := range test.vars {
+				got.set(k, v)
+			}
+			if got != test.want {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+			if got.fallback() != test.fallback {
+				t.Errorf("fallback, expected %v, got %v", test.fallback, got.fallback())
+			}
+		})
+	}
+}
+
+func TestPreferSemiSyncSlaves(t *testing.T) {
+	m := &sql.DB{}
+	async := &sql.DB{}
+	semiSync := &sql.DB{}
+	statuses := map[*sql.DB]dbStatus{
+		m:        {role: RoleMaster},
+		async:    {role: RoleSlave, latency: 1},
+		semiSync: {role: RoleSlave, latency: 2, semiSync: semiSyncStatus{slaveActive: true}},
+	}
+
+	if s := makeSelection(statuses, m); s.slave != async {
+		t.Error("expected slave with the lowest latency without semi-sync preference")
+	}
+	if s := makeSelection(withSemiSync(statuses), m); s.slave != semiSync {
+		t.Error("expected semi-sync slave")
+	}
+}
@@ -0,0 +1,161 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is a persisted snapshot of the last known topology. Servers are
+// identified by names from Config.Names. Identities hold detected server
+// identities, pools connected to the same server share an identity.
+type State struct {
+	Master     string            `json:"master"`
+	Roles      map[string]Role   `json:"roles"`
+	Identities map[string]string `json:"identities,omitempty"`
+	SavedAt    time.Time         `json:"saved_at"`
+}
+
+// StateStore persists last known topology between restarts. Load should
+// return a zero State and nil error if nothing was saved yet.
+type StateStore interface {
+	Load() (State, error)
+	Save(State) error
+}
+
+// FileStore is a StateStore keeping state as a JSON document in a file. File
+// is replaced atomically on every save.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore creates a StateStore persisting state to a file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load reads state from the file. Missing file is not an error.
+func (s *FileStore) Load() (State, error) {
+	var st State
+	buf, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(buf, &st)
+	return st, err
+}
+
+// Save writes state to a temporary file and renames it over the state file.
+func (s *FileStore) Save(st State) error {
+	buf, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *Role) UnmarshalText(b []byte) error {
+	for _, v := range []Role{RoleOffline, RoleSlave, RoleMaster, RoleDelayed} {
+		if v.String() == string(b) {
+			*r = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown role %q", b)
+}
+
+// nodeName returns configured db name or its position in dbs.
+func nodeName(cfg Config, dbs []*sql.DB, db *sql.DB) string {
+	if name, ok := cfg.Names[db]; ok {
+		return name
+	}
+	for i, d := range dbs {
+		if d == db {
+			return fmt.Sprintf("db%d", i)
+		}
+	}
+	return ""
+}
+
+func (p *DBs) name(db *sql.DB) string {
+	return nodeName(p.config, p.dbs, db)
+}
+
+// restoreState loads persisted topology. It returns false if nothing was saved,
+// saved state is older than Config.MaxStateAge or does not describe all dbs.
+func restoreState(dbs []*sql.DB, cfg Config) (map[*sql.DB]dbStatus, *sql.DB, bool) {
+	if cfg.StateStore == nil {
+		return nil, nil, false
+	}
+	st, err := cfg.StateStore.Load()
+	if err != nil {
+		cfg.Logger.Print("dbfailover: loading saved state: ", err)
+		return nil, nil, false
+	}
+
+	if age := time.Since(st.SavedAt); cfg.MaxStateAge > 0 && age > cfg.MaxStateAge {
+		cfg.Logger.Print("dbfailover: ignoring saved state, saved ", age.Round(time.Second), " ago")
+		return nil, nil, false
+	}
+
+	var master *sql.DB
+	state := make(map[*sql.DB]dbStatus)
+	for _, db := range dbs {
+		name := nodeName(cfg, dbs, db)
+		r, ok := st.Roles[name]
+		if !ok {
+			return nil, nil, false
+		}
+		state[db] = dbStatus{role: r, savedIdentity: st.Identities[name]}
+		if name == st.Master {
+			master = db
+		}
+	}
+	return state, master, true
+}
+
+// saveState persists current state, it must be called from run go-routine.
+func (p *DBs) saveState(lastMaster *sql.DB) {
+	st := State{
+		Master:     p.name(lastMaster),
+		Roles:      make(map[string]Role),
+		Identities: make(map[string]string),
+		SavedAt:    time.Now(),
+	}
+	for db, s := range p.state {
+		st.Roles[p.name(db)] = s.role
+		if id := s.identity(); id != "" {
+			st.Identities[p.name(db)] = id
+		}
+	}
+	if err := p.config.StateStore.Save(st); err != nil {
+		p.config.Logger.Print("dbfailover: saving state: ", err)
+	}
+}
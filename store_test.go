@@ -0,0 +1,131 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	st, err := s.Load()
+	if err != nil {
+		t.Fatalf("loading missing state: %v", err)
+	}
+	if !reflect.DeepEqual(st, State{}) {
+		t.Errorf("expected empty state, got %v", st)
+	}
+
+	want := State{
+		Master: "a",
+		Roles: map[string]Role{
+			"a": RoleMaster,
+			"b": RoleSlave,
+			"c": RoleOffline,
+		},
+	}
+	if err := s.Save(want); err != nil {
+		t.Fatalf("saving state: %v", err)
+	}
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("loading state: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	dir := t.TempDir()
+
+	s := NewFileStore(filepath.Join(dir, "state.json"))
+	cfg := Config{
+		Logger:      nopLogger{},
+		StateStore:  s,
+		Names:       map[*sql.DB]string{db1: "a", db2: "b"},
+		MaxStateAge: time.Hour,
+	}
+
+	if _, _, ok := restoreState([]*sql.DB{db1, db2}, cfg); ok {
+		t.Errorf("empty state restored")
+	}
+
+	err := s.Save(State{
+		Master:  "b",
+		Roles:   map[string]Role{"a": RoleSlave, "b": RoleMaster},
+		SavedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("saving state: %v", err)
+	}
+
+	state, master, ok := restoreState([]*sql.DB{db1, db2}, cfg)
+	if !ok {
+		t.Fatalf("state not restored")
+	}
+	if master != db2 {
+		t.Errorf("restored master does not match")
+	}
+	if state[db1].role != RoleSlave || state[db2].role != RoleMaster {
+		t.Errorf("restored roles do not match, got %v", state)
+	}
+
+	if _, _, ok := restoreState([]*sql.DB{db1, db2, {}}, cfg); ok {
+		t.Errorf("state restored with unknown databases")
+	}
+
+	err = s.Save(State{
+		Master:  "b",
+		Roles:   map[string]Role{"a": RoleSlave, "b": RoleMaster},
+		SavedAt: time.Now().Add(-2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("saving state: %v", err)
+	}
+	if _, _, ok := restoreState([]*sql.DB{db1, db2}, cfg); ok {
+		t.Errorf("stale state restored")
+	}
+	cfg.MaxStateAge = -1
+	if _, _, ok := restoreState([]*sql.DB{db1, db2}, cfg); !ok {
+		t.Errorf("state not restored without age limit")
+	}
+}
+
+func TestRestoreDuplicateNodes(t *testing.T) {
+	vip := startOfflineInstance(t)
+	direct := startOfflineInstance(t)
+	slave := startOfflineInstance(t)
+	dbs := []*sql.DB{vip, direct, slave}
+
+	cfg := Config{
+		Logger:     nopLogger{},
+		StateStore: NewFileStore(filepath.Join(t.TempDir(), "state.json")),
+		Names:      map[*sql.DB]string{vip: "vip", direct: "direct", slave: "slave"},
+	}
+	master := dbStatus{role: RoleMaster, serverID: 1, hostname: "db1", port: 3306}
+	p := &DBs{
+		dbs: dbs,
+		state: map[*sql.DB]dbStatus{
+			vip:    master,
+			direct: master,
+			slave:  {role: RoleSlave, serverID: 2, hostname: "db2", port: 3306},
+		},
+		config: cfg,
+	}
+	p.saveState(vip)
+
+	p, err := NewWithConfig(dbs, cfg)
+	if err != nil {
+		t.Fatalf("restoring saved state: %v", err)
+	}
+	defer p.Stop()
+	if m := p.Master(); m != vip {
+		t.Errorf("expected restored master")
+	}
+}
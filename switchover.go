@@ -0,0 +1,261 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrSwitchoverCandidate is returned from Switchover if the requested new
+// master is not currently detected as a healthy slave.
+var ErrSwitchoverCandidate = errors.New("switchover candidate is not a healthy slave")
+
+// Switchover performs a planned master switch to newMaster. It requires
+// MariaDB GTID based replication and runs following steps:
+//
+//  1. sets read_only flag on the current master,
+//  2. waits for newMaster to apply all transactions from the current master,
+//  3. stops replication on newMaster and makes it writable,
+//  4. updates master selection to newMaster,
+//  5. points all other monitored servers, including the old master, to
+//     replicate from newMaster.
+//
+// If any of the first three steps fail the old master is made writable again
+// and the error is returned, topology is left as it was. Failures in the last
+// step are returned after newMaster is already selected, those slaves need to
+// be fixed manually.
+//
+// If ctx is done or DBs is stopped during the fourth step newMaster is already
+// writable and the old master is read-only, but an error is returned without
+// updating selection or pointing slaves to newMaster. Selection is updated by
+// the following checks, slaves need to be pointed to newMaster manually.
+//
+// Context deadline limits the whole operation including waiting for
+// replication catch-up, which is also limited by Config.CatchupTimeout.
+func (p *DBs) Switchover(ctx context.Context, newMaster *sql.DB) error {
+	p.switchover.Lock()
+	defer p.switchover.Unlock()
+
+	if !p.known(newMaster) {
+		return ErrUnknownDatabase
+	}
+
+	err := p.switchoverTo(ctx, newMaster)
+	p.audit(AuditSwitchover, newMaster, err)
+	return err
+}
+
+// SwitchoverCandidate returns the best slave to be promoted by Switchover.
+// Healthy slaves are preferred, then semi-sync slaves if
+// Config.PreferSemiSyncSlaves is set, then direct replicas of the current
+// master and then slaves with the lowest replication delay. It returns
+// ErrSwitchoverCandidate if there are no slaves.
+func (p *DBs) SwitchoverCandidate() (*sql.DB, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	chains := replicationChains(withoutDuplicates(p.state, p.dups), p.active.lastMaster)
+	var (
+		best       *sql.DB
+		bestStatus dbStatus
+	)
+	for _, db := range p.dbs {
+		s := p.state[db]
+		if _, dup := p.dups[db]; dup || s.role != RoleSlave {
+			continue
+		}
+		s.async = p.config.PreferSemiSyncSlaves && !s.semiSync.slaveActive
+		if c, ok := chains[db]; ok {
+			s.depth = c.depth
+		} else {
+			s.depth = len(p.dbs) + 1
+		}
+		// rank by replication delay instead of check latency
+		s.latency = s.delay
+		if best == nil || betterSlave(s, bestStatus) {
+			best = db
+			bestStatus = s
+		}
+	}
+	if best == nil {
+		return nil, ErrSwitchoverCandidate
+	}
+	return best, nil
+}
+
+func (p *DBs) switchoverTo(ctx context.Context, newMaster *sql.DB) error {
+	p.mu.RLock()
+	active := p.active
+	candidate := p.state[newMaster]
+	old := p.state[active.master]
+	p.mu.RUnlock()
+
+	switch {
+	case active.multipleMasters:
+		return ErrMultipleMasters
+	case active.master == nil:
+		return ErrNoMaster
+	case active.master == newMaster:
+		return nil
+	case candidate.role != RoleSlave:
+		return ErrSwitchoverCandidate
+	}
+	oldMaster := active.master
+
+	host, port, err := p.replicationAddr(ctx, newMaster)
+	if err != nil {
+		return fmt.Errorf("detecting new master address: %w", err)
+	}
+
+	if _, err := oldMaster.ExecContext(ctx, "SET GLOBAL read_only = 1"); err != nil {
+		return fmt.Errorf("setting read_only on old master: %w", err)
+	}
+	if err := p.promote(ctx, oldMaster, newMaster); err != nil {
+		if _, rerr := oldMaster.ExecContext(context.Background(), "SET GLOBAL read_only = 0"); rerr != nil {
+			return errors.Join(err, fmt.Errorf("restoring old master: %w", rerr))
+		}
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("updating master selection: %w", ctx.Err())
+	case <-p.done:
+		return fmt.Errorf("updating master selection: %w", ErrStopped)
+	case p.overrides <- []statusUpdate{
+		{db: newMaster, status: dbStatus{role: RoleMaster, latency: candidate.latency, reason: "promoted by switchover"}},
+		{db: oldMaster, status: dbStatus{role: RoleOffline, latency: old.latency, reason: "demoted by switchover"}},
+	}:
+	}
+
+	var errs []error
+	for _, db := range p.dbs {
+		if db == newMaster {
+			continue
+		}
+		p.mu.RLock()
+		s := p.state[db]
+		_, dup := p.dups[db]
+		p.mu.RUnlock()
+		if dup || (db != oldMaster && s.role != RoleSlave && s.role != RoleDelayed) {
+			// aliases are changed through their primary pool
+			continue
+		}
+		if err := changeMaster(ctx, db, host, port, p.config, db == oldMaster); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("pointing slaves to new master: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// promote waits for newMaster to catch-up with read-only oldMaster at most
+// Config.CatchupTimeout and makes newMaster writable. On error replication on
+// newMaster is restarted.
+func (p *DBs) promote(ctx context.Context, oldMaster, newMaster *sql.DB) error {
+	var pos string
+	if err := oldMaster.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_binlog_pos").Scan(&pos); err != nil {
+		return fmt.Errorf("reading old master GTID position: %w", err)
+	}
+
+	var res sql.NullInt64
+	timeout := p.config.CatchupTimeout
+	if err := newMaster.QueryRowContext(ctx, "SELECT MASTER_GTID_WAIT(?, ?)", pos, timeout.Seconds()).Scan(&res); err != nil {
+		return fmt.Errorf("waiting for new master to catch-up: %w", err)
+	}
+	if res.Valid && res.Int64 == -1 {
+		return fmt.Errorf("waiting for new master to catch-up: GTID %s was not applied within %v", pos, timeout)
+	}
+	if !res.Valid || res.Int64 != 0 {
+		return fmt.Errorf("waiting for new master to catch-up: GTID %s was not applied", pos)
+	}
+
+	if _, err := newMaster.ExecContext(ctx, "STOP SLAVE"); err != nil {
+		return fmt.Errorf("stopping replication on new master: %w", err)
+	}
+	if _, err := newMaster.ExecContext(ctx, "SET GLOBAL read_only = 0"); err != nil {
+		err = fmt.Errorf("making new master writable: %w", err)
+		if _, rerr := newMaster.ExecContext(context.Background(), "START SLAVE"); rerr != nil {
+			return errors.Join(err, fmt.Errorf("restarting replication on new master: %w", rerr))
+		}
+		return err
+	}
+	// New master is writable from now on, failing to remove old
+	// replication configuration is not critical, server is detected as
+	// master with stopped replication threads.
+	if _, err := newMaster.ExecContext(ctx, "RESET SLAVE ALL"); err != nil {
+		p.config.Logger.Print("dbfailover: removing replication configuration from new master: ", err)
+	}
+	return nil
+}
+
+// replicationAddr returns host and port used by other servers to replicate
+// from db.
+func (p *DBs) replicationAddr(ctx context.Context, db *sql.DB) (string, int, error) {
+	return nodeAddr(ctx, db, db, p.config)
+}
+
+// nodeAddr returns host and port of db from Config.ReplicationAddrs or queries
+// them using q.
+func nodeAddr(ctx context.Context, q Querier, db *sql.DB, cfg Config) (string, int, error) {
+	if addr, ok := cfg.ReplicationAddrs[db]; ok {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", 0, err
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid port in %q: %w", addr, err)
+		}
+		return host, n, nil
+	}
+
+	var (
+		reportHost sql.NullString
+		hostname   string
+		port       int
+	)
+	err := q.QueryRowContext(ctx, "SELECT @@report_host, @@hostname, @@port").Scan(&reportHost, &hostname, &port)
+	if err != nil {
+		return "", 0, err
+	}
+	if reportHost.String != "" {
+		return reportHost.String, port, nil
+	}
+	return hostname, port, nil
+}
+
+// changeMaster points db to replicate from a server at host:port. Old master
+// does not have replication position yet, its binary log position is used
+// instead.
+func changeMaster(ctx context.Context, db *sql.DB, host string, port int, cfg Config, oldMaster bool) error {
+	gtid := "slave_pos"
+	if oldMaster {
+		gtid = "current_pos"
+	}
+
+	// CHANGE MASTER does not support placeholders
+	stmt := fmt.Sprintf("CHANGE MASTER TO MASTER_HOST = %s, MASTER_PORT = %d", quote(host), port)
+	if cfg.ReplicationUser != "" {
+		stmt += fmt.Sprintf(", MASTER_USER = %s, MASTER_PASSWORD = %s", quote(cfg.ReplicationUser), quote(cfg.ReplicationPassword))
+	}
+	stmt += ", MASTER_USE_GTID = " + gtid
+
+	for _, q := range []string{"STOP SLAVE", stmt, "START SLAVE"} {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("changing master: %w", err)
+		}
+	}
+	return nil
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(s) + "'"
+}
@@ -0,0 +1,133 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSwitchoverValidation(t *testing.T) {
+	db1 := &sql.DB{}
+	db2 := &sql.DB{}
+	db3 := &sql.DB{}
+	state := map[*sql.DB]dbStatus{
+		db1: {role: RoleMaster},
+		db2: {role: RoleSlave},
+		db3: {role: RoleOffline},
+	}
+	p := &DBs{
+		dbs:    []*sql.DB{db1, db2, db3},
+		state:  state,
+		active: makeSelection(state, db1),
+		config: Config{Logger: nopLogger{}},
+	}
+
+	tests := []struct {
+		msg string
+		db  *sql.DB
+		err error
+	}{
+		{msg: "unknown", db: &sql.DB{}, err: ErrUnknownDatabase},
+		{msg: "offline", db: db3, err: ErrSwitchoverCandidate},
+		{msg: "current master", db: db1, err: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			err := p.Switchover(context.Background(), test.db)
+			if err != test.err {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSwitchoverCandidate(t *testing.T) {
+	m := &sql.DB{}
+	async := &sql.DB{}
+	semiSync := &sql.DB{}
+	cascaded := &sql.DB{}
+	state := map[*sql.DB]dbStatus{
+		m:        {role: RoleMaster, serverID: 1},
+		async:    {role: RoleSlave, serverID: 2, masterServerID: 1, delay: time.Second},
+		semiSync: {role: RoleSlave, serverID: 3, masterServerID: 1, delay: 2 * time.Second, semiSync: semiSyncStatus{slaveActive: true}},
+		cascaded: {role: RoleSlave, serverID: 4, masterServerID: 2},
+	}
+	p := &DBs{
+		dbs:    []*sql.DB{m, async, semiSync, cascaded},
+		state:  state,
+		active: makeSelection(state, m),
+		config: Config{Logger: nopLogger{}},
+	}
+
+	if db, err := p.SwitchoverCandidate(); err != nil || db != async {
+		t.Errorf("expected slave with the lowest delay, got %v", err)
+	}
+	p.config.PreferSemiSyncSlaves = true
+	if db, err := p.SwitchoverCandidate(); err != nil || db != semiSync {
+		t.Errorf("expected semi-sync slave, got %v", err)
+	}
+
+	p.state = map[*sql.DB]dbStatus{m: {role: RoleMaster}}
+	if _, err := p.SwitchoverCandidate(); err != ErrSwitchoverCandidate {
+		t.Errorf("expected %v, got %v", ErrSwitchoverCandidate, err)
+	}
+}
+
+func TestSwitchover(t *testing.T) {
+	pool := getDockerPool(t)
+	network := getDockerNetwork(t, pool)
+	defer pool.RemoveNetwork(network)
+
+	adb, masterResource := startMasterInstance(t, pool, network)
+	defer pool.Purge(masterResource)
+
+	bdb, bResource := startSlaveInstance(t, pool, network, adb)
+	defer pool.Purge(bResource)
+
+	cdb, cResource := startSlaveInstance(t, pool, network, adb)
+	defer pool.Purge(cResource)
+
+	p, err := NewWithConfig([]*sql.DB{adb, bdb, cdb}, Config{
+		ReplicationUser:     mariaDBUser,
+		ReplicationPassword: mariaDBPassword,
+		ReplicationAddrs: map[*sql.DB]string{
+			bdb: poolToHost[bdb] + ":3306",
+		},
+	})
+	if err != nil {
+		t.Fatalf("creating DBs failed with: %v", err)
+	}
+	defer p.Stop()
+
+	if _, err := adb.Exec("CREATE TABLE t (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("creating table on master: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Switchover(ctx, bdb); err != nil {
+		t.Fatalf("switchover failed: %v", err)
+	}
+
+	if m := p.Master(); m != bdb {
+		t.Fatalf("master was not switched to B")
+	}
+	if _, err := bdb.Exec("INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatalf("writing to new master: %v", err)
+	}
+
+	for _, db := range []*sql.DB{adb, cdb} {
+		if err := waitForSlaveRunning(db, 5*time.Second); err != nil {
+			t.Fatalf("waiting for slave to replicate from new master: %v", err)
+		}
+	}
+
+	time.Sleep(defaultCheckInterval + 100*time.Millisecond)
+	if m := p.Master(); m != bdb {
+		t.Errorf("master is not B after status checks")
+	}
+	if s := p.Slave(); s != adb && s != cdb {
+		t.Errorf("slave is not A or C after status checks")
+	}
+}
@@ -0,0 +1,136 @@
+package dbfailover
+
+import (
+	"database/sql"
+	"time"
+)
+
+// NodeStatus holds last detected state of a single DB server.
+type NodeStatus struct {
+	DB       *sql.DB
+	Role     Role
+	Latency  time.Duration
+	Degraded bool      // one of Config.Probes marked as Degraded failed
+	FencedAt time.Time // last time server was fenced by FenceStaleMasters
+
+	// SuperWritable is set for read-only servers still writable by users
+	// with SUPER privilege, super_read_only is supported but not set.
+	SuperWritable bool
+
+	// BreakerOpen is set if server is treated as offline because of
+	// errors reported with ReportError.
+	BreakerOpen bool
+
+	// Orphaned is set for slaves not replicating from the selected
+	// master, see Config.DemoteOrphanedSlaves.
+	Orphaned bool
+
+	// Replication path from the selected master, ReplicationDepth is 1
+	// for direct replicas and 0 if server does not replicate from master.
+	// ReplicationDelay is cumulative delay along the path, configured
+	// MASTER_DELAY of delayed slaves is not included.
+	ReplicationDepth int
+	ReplicationDelay time.Duration
+
+	// Semi-synchronous replication state. SemiSyncSlave is set for slaves
+	// acknowledging transactions, SemiSyncClients is the number of such
+	// slaves connected to a master and SemiSyncFallback is set for masters
+	// with semi-sync enabled that fell back to asynchronous replication.
+	SemiSyncSlave    bool
+	SemiSyncClients  int
+	SemiSyncFallback bool
+
+	// Server identity detected by status checks, ServerUUID is available
+	// on MySQL only. DuplicateOf is set if the pool is connected to the
+	// same server as another pool, such duplicates are not used for
+	// master or slave selection.
+	ServerID    uint32
+	ServerUUID  string
+	Hostname    string
+	DuplicateOf *sql.DB
+
+	// Channels lists replication connections and their delay, it is empty
+	// if server is not a slave.
+	Channels []ChannelStatus
+
+	Errors      []*CheckError // failed checks of the last status check
+	LastError   *CheckError   // last check failure, kept after recovery
+	LastErrorAt time.Time
+}
+
+// Topology is a snapshot of detected DB servers state and current master and
+// slave selection.
+type Topology struct {
+	Master          *sql.DB // nil if no master is detected
+	Slave           *sql.DB // nil if no slave or master is detected
+	Delayed         *sql.DB // nil if no delayed slave is detected
+	LastMaster      *sql.DB
+	MultipleMasters bool
+	PinnedMaster    *sql.DB // nil if master is not pinned
+	Version         uint64  // selection version, see DBs.Version
+	Nodes           []NodeStatus
+}
+
+// Topology returns detected state of all monitored DB servers. Nodes are listed
+// in the same order as provided to New().
+//
+// Unlike Master() and Slave() it reports servers state as detected, ignoring
+// master pinning and multiple masters protection.
+func (p *DBs) Topology() Topology {
+	open := p.openBreakers()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	t := Topology{
+		Master:          p.active.master,
+		Slave:           p.active.slave,
+		Delayed:         p.active.delayed,
+		LastMaster:      p.active.lastMaster,
+		MultipleMasters: p.active.multipleMasters,
+		Version:         p.version,
+		Nodes:           make([]NodeStatus, 0, len(p.dbs)),
+	}
+	if p.pin.active(time.Now()) {
+		t.PinnedMaster = p.pin.db
+	}
+	chains := replicationChains(withoutDuplicates(p.state, p.dups), p.active.lastMaster)
+	for _, db := range p.dbs {
+		s := p.state[db]
+		var channels []ChannelStatus
+		if s.channels != nil {
+			channels = append(channels, *s.channels...)
+		}
+		t.Nodes = append(t.Nodes, NodeStatus{
+			DB:       db,
+			Role:     s.role,
+			Latency:  s.latency,
+			Degraded: s.degraded,
+			FencedAt: p.fenced[db],
+
+			SuperWritable: s.superWritable,
+
+			BreakerOpen: open[db],
+			Orphaned:    p.orphans[db],
+
+			ReplicationDepth: chains[db].depth,
+			ReplicationDelay: chains[db].delay,
+
+			SemiSyncSlave:    s.semiSync.slaveActive,
+			SemiSyncClients:  s.semiSync.masterClients,
+			SemiSyncFallback: s.semiSync.fallback(),
+
+			ServerID:    s.serverID,
+			ServerUUID:  s.serverUUID,
+			Hostname:    s.hostname,
+			DuplicateOf: p.dups[db],
+
+			Channels: channels,
+
+			Errors:      s.errs.list(),
+			LastError:   p.errs[db].err,
+			LastErrorAt: p.errs[db].at,
+		})
+	}
+	return t
+}
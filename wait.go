@@ -0,0 +1,80 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// notify marks master/slave selection as changed and wakes up all waiters. It
+// must be called with p.mu write lock held.
+func (p *DBs) notify() {
+	p.version++
+	if p.changed != nil {
+		close(p.changed)
+	}
+	p.changed = make(chan struct{})
+}
+
+// watch returns current selection version and a channel closed on the next
+// change.
+func (p *DBs) watch() (uint64, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return p.version, p.changed
+}
+
+// Version returns current version of master/slave selection. Version is
+// increased every time selected servers change or master is pinned or
+// unpinned.
+func (p *DBs) Version() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.version
+}
+
+// WaitForChange blocks until selection version differs from version and
+// returns the new version. It returns immediately if version is already
+// outdated. Context error is returned if ctx is done first.
+func (p *DBs) WaitForChange(ctx context.Context, version uint64) (uint64, error) {
+	for {
+		v, changed := p.watch()
+		if v != version {
+			return v, nil
+		}
+		select {
+		case <-ctx.Done():
+			return v, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// WaitForMaster blocks until a single writable master is detected (or master
+// is pinned) and returns it. Context error is returned if ctx is done first.
+func (p *DBs) WaitForMaster(ctx context.Context) (*sql.DB, error) {
+	for {
+		_, changed := p.watch()
+
+		p.mu.RLock()
+		active := p.active
+		pin := p.pin
+		p.mu.RUnlock()
+
+		if pin.active(time.Now()) {
+			return pin.db, nil
+		}
+		if active.master != nil && !active.multipleMasters {
+			return active.master, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package dbfailover
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestWaitForMaster(t *testing.T) {
+	db1 := &sql.DB{}
+	p := &DBs{
+		dbs:   []*sql.DB{db1},
+		state: map[*sql.DB]dbStatus{db1: {role: RoleOffline}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.WaitForMaster(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v without master, got %v", context.DeadlineExceeded, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.mu.Lock()
+		p.active = selection{master: db1, slave: db1, lastMaster: db1}
+		p.notify()
+		p.mu.Unlock()
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := p.WaitForMaster(ctx)
+	if err != nil {
+		t.Fatalf("waiting for master: %v", err)
+	}
+	if m != db1 {
+		t.Errorf("master does not match")
+	}
+}
+
+func TestWaitForChange(t *testing.T) {
+	db1 := &sql.DB{}
+	p := &DBs{
+		dbs:    []*sql.DB{db1},
+		state:  map[*sql.DB]dbStatus{db1: {role: RoleMaster}},
+		config: Config{Logger: nopLogger{}},
+	}
+	v := p.Version()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.WaitForChange(ctx, v); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v without changes, got %v", context.DeadlineExceeded, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = p.PinMaster(db1, 0)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	nv, err := p.WaitForChange(ctx, v)
+	if err != nil {
+		t.Fatalf("waiting for change: %v", err)
+	}
+	if nv == v {
+		t.Errorf("version was not changed")
+	}
+
+	// outdated version returns immediately
+	if got, err := p.WaitForChange(context.Background(), v); err != nil || got != nv {
+		t.Errorf("expected %v, got %v, %v", nv, got, err)
+	}
+}